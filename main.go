@@ -2,17 +2,56 @@ package main
 
 import (
 	"example.com/hello/raytracer"
+	"flag"
+	"fmt"
+	"image"
 	"image/png"
+	"net/http"
 	"os"
+	"strings"
 )
 
 func main() {
 	// CPU profiling by default
 	// defer profile.Start().Stop()
 
+	workerAddress := flag.String("worker", "", "serve as a render worker on this address, eg :8700")
+	workerURLs := flag.String("workers", "", "comma separated urls of render workers to render on, eg http://render1:8700")
+	serviceAddress := flag.String("serve", "", "serve as a render service queueing jobs on this address, eg :8800")
+	concurrency := flag.Int("concurrency", 1, "renders the render service runs at the same time")
+	flag.Parse()
+
+	if *serviceAddress != "" {
+		fmt.Printf("Render service listening on %s\n", *serviceAddress)
+		if err := http.ListenAndServe(*serviceAddress, raytracer.NewRenderService(*concurrency)); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *workerAddress != "" {
+		fmt.Printf("Render worker listening on %s\n", *workerAddress)
+		if err := http.ListenAndServe(*workerAddress, raytracer.NewRenderWorker()); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	imageLocation := "out.png"
 	imageSpec, scene := raytracer.ExampleRegression(640, 380, "./")
-	myImage := raytracer.GenerateImage(imageSpec, scene)
+	var myImage *image.RGBA
+	if *workerURLs != "" {
+		var err error
+		myImage, err = raytracer.GenerateImageDistributed(imageSpec, scene, raytracer.DistributedSpec{
+			WorkerURLs:     strings.Split(*workerURLs, ","),
+			TilesPerWorker: imageSpec.WorkerCount,
+		})
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		myImage = raytracer.GenerateImage(imageSpec, scene)
+	}
 
 	outputFile, err := os.Create(imageLocation)
 	if err != nil {
@@ -18,9 +18,10 @@ type boundingVolumeHierarchyNode struct {
 }
 
 type boundingVolumeHierarchy struct {
-	root    boundingVolumeHierarchyNode
-	extents []r3.Vec
-	shapes  *[]Shape
+	root      boundingVolumeHierarchyNode
+	extents   []r3.Vec
+	shapes    *[]Shape
+	unbounded []*Shape // shapes with infinite bounds (eg planes) can't be split into quadrants, so are always tested
 }
 
 // bounding box hierarchy where boundaries are computed in a box shape
@@ -30,6 +31,9 @@ func NewBoundingVolumeHierarchy(shapes *[]Shape) *boundingVolumeHierarchy {
 	pMax := r3.Vec{X: float64(math.MinInt64), Y: float64(math.MinInt64), Z: float64(math.MinInt64)}
 	for _, s := range *shapes {
 		lowest, highest := s.computeSquareBounds()
+		if isUnbounded(lowest, highest) {
+			continue
+		}
 		pMin.X = math.Min(pMin.X, lowest.X)
 		pMin.Y = math.Min(pMin.Y, lowest.Y)
 		pMin.Z = math.Min(pMin.Z, lowest.Z)
@@ -53,10 +57,16 @@ func NewBoundingVolumeHierarchy(shapes *[]Shape) *boundingVolumeHierarchy {
 		},
 	}
 
+	// fixed seed so the same shapes always build the same tree
+	jitter := rand.New(rand.NewSource(bvhCentroidJitterSeed))
 	nodeCounter := 1
 	for i := 0; i < len(*shapes); i++ {
 		ptr := &(*shapes)[i]
-		addToBVH(&bvh.root, ptr, &nodeCounter)
+		if isUnbounded((*ptr).computeSquareBounds()) {
+			bvh.unbounded = append(bvh.unbounded, ptr)
+			continue
+		}
+		addToBVH(&bvh.root, ptr, &nodeCounter, jitter)
 	}
 	bvh.recomputeBounds()
 
@@ -75,7 +85,23 @@ func (bvh boundingVolumeHierarchy) getTraceFunction(bvhExploreAlgorithm Bounding
 }
 
 func (bvh boundingVolumeHierarchy) traceRecursively(r *ray, tMin float64) (hit bool, record *hitRecord) {
-	return traceDownBoundingVolumeHierarchyNode(r, tMin, math.MaxFloat64, &bvh.root)
+	hr := bvh.traceUnbounded(r, tMin)
+	if didHit, nodeHr := traceDownBoundingVolumeHierarchyNode(r, tMin, math.MaxFloat64, &bvh.root); didHit && nodeHr.t > tMin && nodeHr.t < hr.t {
+		hr = *nodeHr
+	}
+	return hr.t != math.MaxFloat64, &hr
+}
+
+// returns the closest hit of the shapes that are not in the tree, or a record with t of math.MaxFloat64
+func (bvh boundingVolumeHierarchy) traceUnbounded(r *ray, tMin float64) hitRecord {
+	hr := hitRecord{t: math.MaxFloat64}
+	for _, s := range bvh.unbounded {
+		shapeHr := (*s).hit(r, tMin, hr.t)
+		if shapeHr.t > 0.0 && shapeHr.t < hr.t {
+			hr = shapeHr
+		}
+	}
+	return hr
 }
 
 func (bvh boundingVolumeHierarchy) trace(r *ray, tMin float64) (hit bool, record *hitRecord) {
@@ -85,7 +111,7 @@ func (bvh boundingVolumeHierarchy) trace(r *ray, tMin float64) (hit bool, record
 		t:     0,
 	})
 	heap.Init(&minHeap)
-	hr := hitRecord{t: math.MaxFloat64}
+	hr := bvh.traceUnbounded(r, tMin)
 	for minHeap.Len() > 0 {
 		item := heap.Pop(&minHeap).(*Item)
 		node := item.value
@@ -157,6 +183,11 @@ func traceDownBoundingVolumeHierarchyNode(r *ray, tMin float64, tMax float64, no
 	}
 }
 
+func isUnbounded(lowest r3.Vec, highest r3.Vec) bool {
+	return math.IsInf(lowest.X, 0) || math.IsInf(lowest.Y, 0) || math.IsInf(lowest.Z, 0) ||
+		math.IsInf(highest.X, 0) || math.IsInf(highest.Y, 0) || math.IsInf(highest.Z, 0)
+}
+
 // recomputes the bounds for all objects in the BVH, from bottom up
 func (bvh boundingVolumeHierarchy) recomputeBounds() {
 	recomputeNodeBounds(&bvh.root)
@@ -227,6 +258,7 @@ func addToBVH(
 	curr *boundingVolumeHierarchyNode,
 	shape *Shape,
 	nodeCounter *int,
+	jitter *rand.Rand,
 ) {
 	if curr.leaf {
 		// empty leaf node, feel free to add
@@ -242,14 +274,14 @@ func addToBVH(
 			curr.shape = nil
 
 			// recursive call to same node, now that it isn't a leaf it should add it
-			addToBVH(curr, &removedShape, nodeCounter)
-			addToBVH(curr, shape, nodeCounter)
+			addToBVH(curr, &removedShape, nodeCounter, jitter)
+			addToBVH(curr, shape, nodeCounter, jitter)
 			return
 		}
 	} else {
 		// delegate adding it to the node down
-		ptr := curr.children[getBvhQuadrantIndex(shape, &curr.pMin, &curr.pMax)]
-		addToBVH(ptr, shape, nodeCounter)
+		ptr := curr.children[getBvhQuadrantIndex(shape, &curr.pMin, &curr.pMax, jitter)]
+		addToBVH(ptr, shape, nodeCounter, jitter)
 		return
 	}
 }
@@ -263,8 +295,8 @@ func addToBVH(
 // back top left = 6
 // back top right = 7
 // to prevent two shapes from having the same centroid coordinates, we add a random jitter factor to each centroid
-func getBvhQuadrantIndex(s *Shape, pMin *r3.Vec, pMax *r3.Vec) uint8 {
-	centroid := r3.Add((*s).centroid(), r3.Scale(bvhCentroidJitterFactor, r3.Vec{X: rand.Float64(), Y: rand.Float64(), Z: rand.Float64()}))
+func getBvhQuadrantIndex(s *Shape, pMin *r3.Vec, pMax *r3.Vec, jitter *rand.Rand) uint8 {
+	centroid := r3.Add((*s).centroid(), r3.Scale(bvhCentroidJitterFactor, r3.Vec{X: jitter.Float64(), Y: jitter.Float64(), Z: jitter.Float64()}))
 	idx := uint8(0)
 	if centroid.X > pMin.X+(pMax.X-pMin.X)/2 {
 		idx += 1
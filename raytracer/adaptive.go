@@ -0,0 +1,75 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"math"
+)
+
+// keeps taking samples of a pixel until its color is known well enough, so flat areas like the sky stop after
+// AntiAliasingFactor samples while noisy areas like the edges of glass get up to MaxSamples
+type AdaptiveSampling struct {
+	NoiseThreshold float64 // largest standard error of the mean brightness of a pixel, eg 0.005
+	MaxSamples     int
+}
+
+// running mean and variance of the brightness of the samples of a pixel, see Welford's online algorithm
+type pixelStatistics struct {
+	count int
+	mean  float64
+	m2    float64 // sum of the squared differences from the mean
+}
+
+func (p *pixelStatistics) add(c r3.Vec) {
+	p.count++
+	l := luminance(c)
+	delta := l - p.mean
+	p.mean += delta / float64(p.count)
+	p.m2 += delta * (l - p.mean)
+}
+
+// how far the mean brightness is likely to be from the true brightness of the pixel
+func (p *pixelStatistics) standardError() float64 {
+	if p.count < 2 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(p.m2 / float64(p.count-1) / float64(p.count))
+}
+
+// whether another sample should be taken, always at least AntiAliasingFactor and at least 2 to estimate the noise
+func (is *ImageSpec) needsSample(stats *pixelStatistics) bool {
+	if stats.count < is.AntiAliasingFactor {
+		return true
+	}
+	a := is.AdaptiveSampling
+	if a == nil || stats.count >= a.MaxSamples {
+		return false
+	}
+	return stats.standardError() > a.NoiseThreshold
+}
+
+func luminance(c r3.Vec) float64 {
+	return 0.2126*c.X + 0.7152*c.Y + 0.0722*c.Z
+}
+
+// samples taken per pixel, blue for the fewest samples in the image through green to red for the most
+func (fb *Framebuffer) SampleHeatMap() *image.RGBA {
+	minSamples, maxSamples := math.MaxInt32, 0
+	for _, n := range fb.Samples {
+		minSamples = int(math.Min(float64(minSamples), float64(n)))
+		maxSamples = int(math.Max(float64(maxSamples), float64(n)))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, fb.Width, fb.Height))
+	for i, n := range fb.Samples {
+		t := 0.0
+		if maxSamples > minSamples {
+			t = float64(n-minSamples) / float64(maxSamples-minSamples)
+		}
+		c := r3.Vec{X: math.Max(0, 2*t-1), Y: 1 - math.Abs(2*t-1), Z: math.Max(0, 1-2*t)}
+		img.Pix[i*4+0] = uint8(c.X * 255)
+		img.Pix[i*4+1] = uint8(c.Y * 255)
+		img.Pix[i*4+2] = uint8(c.Z * 255)
+		img.Pix[i*4+3] = 255
+	}
+	return img
+}
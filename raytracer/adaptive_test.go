@@ -0,0 +1,49 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestPixelStatistics(t *testing.T) {
+	stats := pixelStatistics{}
+	for _, l := range []float64{0.2, 0.4, 0.6, 0.8} {
+		stats.add(r3.Vec{X: l, Y: l, Z: l})
+	}
+	// sample variance of 0.2, 0.4, 0.6, 0.8 is 0.2/3
+	if math.Abs(stats.mean-0.5) > 1e-9 || math.Abs(stats.standardError()-math.Sqrt(0.2/3/4)) > 1e-9 {
+		t.Errorf("expected mean 0.5 and standard error %f, got %f and %f", math.Sqrt(0.2/3/4), stats.mean, stats.standardError())
+	}
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	imageSpec.SoftShadowMonteCarloRepetitions = 2
+	imageSpec.AdaptiveSampling = &AdaptiveSampling{NoiseThreshold: 0.002, MaxSamples: 64}
+	var fb *Framebuffer
+	withoutStdout(t, func() {
+		fb = RenderFramebuffer(imageSpec, scene)
+	})
+
+	// the background above the spheres is flat black
+	if n := fb.Samples[0]; n != imageSpec.AntiAliasingFactor {
+		t.Errorf("expected the background to stop after %d samples, got %d", imageSpec.AntiAliasingFactor, n)
+	}
+	maxSamples := 0
+	for _, n := range fb.Samples {
+		if n < imageSpec.AntiAliasingFactor || n > imageSpec.AdaptiveSampling.MaxSamples {
+			t.Fatalf("expected between %d and %d samples, got %d", imageSpec.AntiAliasingFactor, imageSpec.AdaptiveSampling.MaxSamples, n)
+		}
+		maxSamples = int(math.Max(float64(maxSamples), float64(n)))
+	}
+	if maxSamples != imageSpec.AdaptiveSampling.MaxSamples {
+		t.Errorf("expected noisy pixels to take %d samples, the most was %d", imageSpec.AdaptiveSampling.MaxSamples, maxSamples)
+	}
+
+	heatMap := fb.SampleHeatMap()
+	if heatMap.Pix[2] != 255 || heatMap.Pix[0] != 0 {
+		t.Errorf("expected the background to be blue in the heat map, got %v", heatMap.Pix[:4])
+	}
+}
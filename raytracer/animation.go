@@ -0,0 +1,310 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type Easing int
+
+// curve used to interpolate from a keyframe to the next one
+const (
+	EaseLinear = iota
+	EaseIn
+	EaseOut
+	EaseInOut
+	EaseStep // holds the value until the next keyframe
+)
+
+type FloatKeyframe struct {
+	Time   float64 // in seconds
+	Value  float64
+	Easing Easing
+}
+
+type VecKeyframe struct {
+	Time   float64 // in seconds
+	Value  r3.Vec
+	Easing Easing
+}
+
+// keyframes sorted by time, an empty track leaves the value as it is in the scene
+type FloatTrack []FloatKeyframe
+type VecTrack []VecKeyframe
+
+// properties of a scene that change over time, rendered into frames by RenderSequence
+type Animation struct {
+	Duration  float64 // in seconds
+	FrameRate float64 // frames per second
+	// fraction of each frame the shutter is open for, 0.5 matches a 180 degree film shutter
+	// shapes wrapped in a MovingShape blur across it, with their motion timed in seconds
+	ShutterFraction float64
+
+	CameraLookFrom      VecTrack
+	CameraLookAt        VecTrack
+	CameraFocusPoint    VecTrack
+	CameraFocusDistance FloatTrack
+	CameraFov           FloatTrack
+	CameraAperature     FloatTrack
+	FocalLength         FloatTrack // of the PhysicalCamera of the scene
+	FNumber             FloatTrack // of the PhysicalCamera of the scene
+
+	Lights []LightAnimation
+	Shapes []ShapeAnimation // animating shapes rebuilds the bounding volume hierarchy for every frame
+}
+
+// animates the light at Index in Scene.Lights, tracks that don't apply to the type of light are ignored
+type LightAnimation struct {
+	Index          int
+	ColorFrac      VecTrack
+	Position       VecTrack
+	LookAt         VecTrack
+	LightIntensity FloatTrack
+	Angle          FloatTrack
+}
+
+// animates the transform of a shape, the transform is replaced with scale then rotation around Pivot then translation
+type ShapeAnimation struct {
+	Shape       *TransformedShape
+	Pivot       r3.Vec
+	Translation VecTrack
+	Rotation    VecTrack // in degrees
+	Scale       VecTrack // empty leaves the shape unscaled
+}
+
+// where RenderSequence writes frames to, as <Directory>/<FilePrefix>0000.png
+type SequenceOutput struct {
+	Directory   string
+	FilePrefix  string
+	GIFFileName string // optional animated gif of all of the frames, written in Directory
+}
+
+func ease(easing Easing, f float64) float64 {
+	switch easing {
+	case EaseLinear:
+		return f
+	case EaseIn:
+		return f * f
+	case EaseOut:
+		return f * (2 - f)
+	case EaseInOut:
+		return f * f * (3 - 2*f)
+	case EaseStep:
+		return 0
+	default:
+		panic(fmt.Sprintf("No easing found for %d", easing))
+	}
+}
+
+// finds the keyframe at or before time and how far it is to the next one, before the first and after the last keyframe the value is held
+func findKeyframe(count int, keyframeTime func(i int) float64, time float64) (i int, f float64) {
+	next := sort.Search(count, func(i int) bool {
+		return keyframeTime(i) > time
+	})
+	if next == 0 {
+		return 0, 0
+	}
+	if next == count {
+		return count - 1, 0
+	}
+	return next - 1, (time - keyframeTime(next-1)) / (keyframeTime(next) - keyframeTime(next-1))
+}
+
+func (track FloatTrack) valueAt(time float64, value float64) float64 {
+	if len(track) == 0 {
+		return value
+	}
+	i, f := findKeyframe(len(track), func(i int) float64 { return track[i].Time }, time)
+	if f == 0 {
+		return track[i].Value
+	}
+	f = ease(track[i].Easing, f)
+	return track[i].Value + f*(track[i+1].Value-track[i].Value)
+}
+
+func (track VecTrack) valueAt(time float64, value r3.Vec) r3.Vec {
+	if len(track) == 0 {
+		return value
+	}
+	i, f := findKeyframe(len(track), func(i int) float64 { return track[i].Time }, time)
+	if f == 0 {
+		return track[i].Value
+	}
+	f = ease(track[i].Easing, f)
+	return r3.Add(track[i].Value, r3.Scale(f, r3.Sub(track[i+1].Value, track[i].Value)))
+}
+
+func (a Animation) FrameCount() int {
+	return int(math.Round(a.Duration * a.FrameRate))
+}
+
+// checks the lights animated are in the scene, so a sequence fails before its first frame renders
+func (a Animation) validate(scene *Scene) error {
+	for _, la := range a.Lights {
+		if la.Index < 0 || la.Index >= len(scene.Lights) {
+			return fmt.Errorf("no light %d to animate, the scene has %d lights", la.Index, len(scene.Lights))
+		}
+		switch scene.Lights[la.Index].(type) {
+		case AmbientLight, PointLight, SpotLight:
+		default:
+			return fmt.Errorf("no light animation found for %T", scene.Lights[la.Index])
+		}
+	}
+	return nil
+}
+
+// returns the scene at a time in seconds, the animated shapes are updated in place
+// panics when a light animation has no light in the scene, RenderSequence checks them first
+func (a Animation) SceneAt(scene Scene, time float64) Scene {
+	scene.CameraLookFrom = a.CameraLookFrom.valueAt(time, scene.CameraLookFrom)
+	scene.CameraLookAt = a.CameraLookAt.valueAt(time, scene.CameraLookAt)
+	if len(a.CameraFocusPoint) > 0 {
+		focusPoint := a.CameraFocusPoint.valueAt(time, r3.Vec{})
+		scene.CameraFocusPoint = &focusPoint
+	}
+	scene.CameraFocusDistance = a.CameraFocusDistance.valueAt(time, scene.CameraFocusDistance)
+	scene.CameraFov = a.CameraFov.valueAt(time, scene.CameraFov)
+	scene.CameraAperature = a.CameraAperature.valueAt(time, scene.CameraAperature)
+	if scene.PhysicalCamera != nil {
+		physicalCamera := *scene.PhysicalCamera
+		physicalCamera.FocalLength = a.FocalLength.valueAt(time, physicalCamera.FocalLength)
+		physicalCamera.FNumber = a.FNumber.valueAt(time, physicalCamera.FNumber)
+		scene.PhysicalCamera = &physicalCamera
+	}
+	scene.CameraShutterOpen = time
+	scene.CameraShutterClose = time
+	if a.FrameRate > 0 {
+		scene.CameraShutterClose += a.ShutterFraction / a.FrameRate
+	}
+
+	// copy the lights so the scene that was passed in isn't changed
+	scene.Lights = append([]Light{}, scene.Lights...)
+	for _, la := range a.Lights {
+		scene.Lights[la.Index] = la.lightAt(scene.Lights[la.Index], time)
+	}
+	for _, sa := range a.Shapes {
+		sa.Shape.SetTransform(sa.transformAt(time))
+	}
+	return scene
+}
+
+func (la LightAnimation) lightAt(light Light, time float64) Light {
+	switch l := light.(type) {
+	case AmbientLight:
+		l.ColorFrac = la.ColorFrac.valueAt(time, l.ColorFrac)
+		l.LightIntensity = la.LightIntensity.valueAt(time, l.LightIntensity)
+		return l
+	case PointLight:
+		l.ColorFrac = la.ColorFrac.valueAt(time, l.ColorFrac)
+		l.Position = la.Position.valueAt(time, l.Position)
+		l.LightIntensity = la.LightIntensity.valueAt(time, l.LightIntensity)
+		return l
+	case SpotLight:
+		l.ColorFrac = la.ColorFrac.valueAt(time, l.ColorFrac)
+		l.Position = la.Position.valueAt(time, l.Position)
+		l.LookAt = la.LookAt.valueAt(time, l.LookAt)
+		l.LightIntensity = la.LightIntensity.valueAt(time, l.LightIntensity)
+		l.Angle = la.Angle.valueAt(time, l.Angle)
+		return l
+	default:
+		panic(fmt.Sprintf("No light animation found for %T", light))
+	}
+}
+
+func (sa ShapeAnimation) transformAt(time float64) Matrix4 {
+	return ComposeMatrices(
+		PivotMatrix(ScalingMatrix(sa.Scale.valueAt(time, r3.Vec{X: 1, Y: 1, Z: 1})), sa.Pivot),
+		PivotMatrix(RotationMatrix(sa.Rotation.valueAt(time, r3.Vec{})), sa.Pivot),
+		TranslationMatrix(sa.Translation.valueAt(time, r3.Vec{})),
+	)
+}
+
+// renders every frame of the animation to numbered png files, and optionally an animated gif
+// the bounding volume hierarchy is only built once when no shapes are animated
+func RenderSequence(imageSpec ImageSpec, scene Scene, animation Animation, output SequenceOutput) error {
+	if err := scene.validate(); err != nil {
+		return err
+	}
+	if err := animation.validate(&scene); err != nil {
+		return err
+	}
+	var bvh *boundingVolumeHierarchy
+	if len(animation.Shapes) == 0 {
+		shapes := scene.renderShapes()
+		bvh = NewBoundingVolumeHierarchy(&shapes)
+	}
+
+	frameCount := animation.FrameCount()
+	frames := make([]*image.Paletted, 0, frameCount)
+	startTime := time.Now()
+	for frame := 0; frame < frameCount; frame++ {
+		fmt.Printf("Rendering frame %d of %d\n", frame+1, frameCount)
+		frameScene := animation.SceneAt(scene, float64(frame)/animation.FrameRate)
+		frameBvh := bvh
+		if frameBvh == nil {
+			shapes := frameScene.renderShapes()
+			frameBvh = NewBoundingVolumeHierarchy(&shapes)
+		}
+		// every frame gets its own noise, the same seed still renders the same sequence
+		frameSpec := imageSpec
+		frameSpec.Seed += int64(frame)
+		img := traceImage(frameSpec, frameScene, frameBvh)
+
+		if err := writePNG(filepath.Join(output.Directory, fmt.Sprintf("%s%04d.png", output.FilePrefix, frame)), img); err != nil {
+			return err
+		}
+		if output.GIFFileName != "" {
+			paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+			draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+			frames = append(frames, paletted)
+		}
+	}
+
+	if output.GIFFileName != "" {
+		if err := writeGIF(filepath.Join(output.Directory, output.GIFFileName), frames, animation.FrameRate); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Finished rendering %d frames in %s\n", frameCount, time.Since(startTime).String())
+	return nil
+}
+
+func writePNG(fileName string, img image.Image) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeGIF(fileName string, frames []*image.Paletted, frameRate float64) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	// gif delays are in 100ths of a second
+	delays := make([]int, len(frames))
+	for i := range delays {
+		delays[i] = int(math.Round(100 / frameRate))
+	}
+	if err := gif.EncodeAll(file, &gif.GIF{Image: frames, Delay: delays}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,105 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnimationTracks(t *testing.T) {
+	track := FloatTrack{
+		{Time: 0, Value: 0, Easing: EaseLinear},
+		{Time: 1, Value: 10, Easing: EaseInOut},
+		{Time: 2, Value: 20, Easing: EaseStep},
+		{Time: 3, Value: 30},
+	}
+	for _, test := range []struct{ time, value float64 }{
+		{-1, 0},
+		{0.25, 2.5},
+		{1.5, 15}, // ease in and out is symmetric around the middle
+		{1.25, 11.5625},
+		{2.9, 20},
+		{4, 30},
+	} {
+		if value := track.valueAt(test.time, -1); math.Abs(value-test.value) > 1e-9 {
+			t.Errorf("expected %f at time %f, got %f", test.value, test.time, value)
+		}
+	}
+	if value := (FloatTrack{}).valueAt(1, 42); value != 42 {
+		t.Errorf("expected an empty track to keep the value 42, got %f", value)
+	}
+
+	scene := Scene{
+		CameraLookFrom: r3.Vec{Z: -5},
+		Lights:         []Light{PointLight{Position: r3.Vec{Y: 1}, LightIntensity: 1}},
+	}
+	animation := Animation{
+		Duration:        2,
+		FrameRate:       2,
+		ShutterFraction: 0.5,
+		CameraLookFrom:  VecTrack{{Time: 0, Value: r3.Vec{Z: -5}}, {Time: 2, Value: r3.Vec{X: 4, Z: -5}}},
+		Lights:          []LightAnimation{{Index: 0, LightIntensity: FloatTrack{{Time: 0, Value: 1}, {Time: 2, Value: 3}}}},
+	}
+	frameScene := animation.SceneAt(scene, 1)
+	if r3.Norm(r3.Sub(frameScene.CameraLookFrom, r3.Vec{X: 2, Z: -5})) > 1e-9 {
+		t.Errorf("expected camera at {2 0 -5}, got %v", frameScene.CameraLookFrom)
+	}
+	if intensity := frameScene.Lights[0].getLightIntensity(); math.Abs(intensity-2) > 1e-9 {
+		t.Errorf("expected light intensity 2, got %f", intensity)
+	}
+	if scene.Lights[0].getLightIntensity() != 1 {
+		t.Errorf("expected the original scene to be left unchanged")
+	}
+	if frameScene.CameraShutterOpen != 1 || frameScene.CameraShutterClose != 1.25 {
+		t.Errorf("expected shutter open from 1 to 1.25, got %f to %f", frameScene.CameraShutterOpen, frameScene.CameraShutterClose)
+	}
+}
+
+func TestRenderSequence(t *testing.T) {
+	sphere := NewTransformedShape(&Sphere{Center: r3.Vec{}, Radius: 1, Mat: Standard{ColorFrac: r3.Vec{X: 1}}}, IdentityMatrix())
+	scene := Scene{
+		CameraLookFrom: r3.Vec{Z: -5},
+		CameraUp:       r3.Vec{Y: 1},
+		CameraFov:      60,
+		Shapes:         []Shape{sphere},
+	}
+	animation := Animation{
+		Duration:  1,
+		FrameRate: 3,
+		Shapes:    []ShapeAnimation{{Shape: sphere, Translation: VecTrack{{Time: 0, Value: r3.Vec{X: -1}}, {Time: 1, Value: r3.Vec{X: 1}}}}},
+	}
+	imageSpec := ImageSpec{Width: 8, Height: 6, AntiAliasingFactor: 1, RayTracingMaxDepth: 2, WorkerCount: 1}
+	dir := t.TempDir()
+	if err := RenderSequence(imageSpec, scene, animation, SequenceOutput{Directory: dir, FilePrefix: "frame", GIFFileName: "anim.gif"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"frame0000.png", "frame0001.png", "frame0002.png", "anim.gif"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+}
+
+func TestRenderSequenceChecksLights(t *testing.T) {
+	scene := Scene{Lights: []Light{PointLight{}}}
+	animation := Animation{Duration: 1, FrameRate: 3, Lights: []LightAnimation{{Index: 1}}}
+	imageSpec := ImageSpec{Width: 8, Height: 6, AntiAliasingFactor: 1, RayTracingMaxDepth: 2, WorkerCount: 1}
+	dir := t.TempDir()
+	if err := RenderSequence(imageSpec, scene, animation, SequenceOutput{Directory: dir, FilePrefix: "frame"}); err == nil {
+		t.Errorf("expected an error animating a light that is not in the scene")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "frame0000.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no frame to be rendered, got %v", err)
+	}
+
+	// nor are scenes that would panic while rendering
+	scene.CameraProjection = 99
+	if err := RenderSequence(imageSpec, scene, Animation{Duration: 1, FrameRate: 3}, SequenceOutput{Directory: dir, FilePrefix: "frame"}); err == nil {
+		t.Errorf("expected an error rendering a scene with an unknown camera projection")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "frame0000.png")); !os.IsNotExist(err) {
+		t.Errorf("expected no frame to be rendered, got %v", err)
+	}
+}
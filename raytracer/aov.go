@@ -0,0 +1,285 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"hash/fnv"
+	"image"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// arbitrary output variable, an extra image made from the first surface each camera ray hits, for compositing and denoising
+type AOV int
+
+const (
+	DepthAOV        = iota // distance from the camera along the ray, in all three channels, 0 where no ray hits
+	NormalAOV              // world space, components from -1 to 1
+	CameraNormalAOV        // x to the right of the image, y up and z towards the camera
+	AlbedoAOV              // color of the material without lighting
+	PositionAOV            // world space, 0 where no ray hits
+	ShapeIDAOV             // a color for every shape, so shapes can be told apart
+	MaterialIDAOV          // a color for every material
+)
+
+// AOVs to render, and masks covering some of the shapes or materials
+type AOVSpec struct {
+	AOVs          []AOV
+	ShapeMasks    map[string][]Shape    // name of the mask to shapes of Scene.Shapes, the mask is 1 where they are hit
+	MaterialMasks map[string][]Material // name of the mask to materials, the mask is 1 where they are hit
+}
+
+// AOVs and masks by layer name, eg "depth", "normal" or "mask.floor"
+// each is averaged over the AntiAliasingFactor samples of a pixel, so edges are anti aliased like the image
+type AOVImages struct {
+	Width  int
+	Height int
+	Layers map[string]*Framebuffer
+}
+
+func (aov AOV) layerName() string {
+	switch aov {
+	case DepthAOV:
+		return "depth"
+	case NormalAOV:
+		return "normal"
+	case CameraNormalAOV:
+		return "cameraNormal"
+	case AlbedoAOV:
+		return "albedo"
+	case PositionAOV:
+		return "position"
+	case ShapeIDAOV:
+		return "shapeID"
+	case MaterialIDAOV:
+		return "materialID"
+	default:
+		panic(fmt.Sprintf("No AOV found for %d", aov))
+	}
+}
+
+// marks hits with the index of the render shape, so the ID is the same whichever part of the shape was hit
+type aovShape struct {
+	Shape
+	id int
+}
+
+func (s aovShape) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	hr := s.Shape.hit(r, tMin, tMax)
+	hr.shapeID = s.id + 1
+	return hr
+}
+
+// traces the camera rays of the pixels of the image spec, with the same samples as the image, and keeps what they hit first
+// the atmosphere is left out, so the AOVs show the surfaces behind it
+func RenderAOVs(imageSpec ImageSpec, scene Scene, spec AOVSpec) *AOVImages {
+	scene.Atmosphere = nil
+	renderShapes := scene.renderShapes()
+	shapes := make([]Shape, len(renderShapes))
+	for i, s := range renderShapes {
+		shapes[i] = aovShape{Shape: s, id: i}
+	}
+	bvh := NewBoundingVolumeHierarchy(&shapes)
+	cam := scene.camera(float64(imageSpec.Width) / float64(imageSpec.Height))
+	cam.setShutter(scene.shutter())
+	frame := newCameraFrame(scene.CameraLookFrom, scene.CameraLookAt, scene.CameraUp)
+
+	images := &AOVImages{Width: imageSpec.Width, Height: imageSpec.Height, Layers: map[string]*Framebuffer{}}
+	for _, aov := range spec.AOVs {
+		images.Layers[aov.layerName()] = NewFramebuffer(imageSpec.Width, imageSpec.Height)
+	}
+	for name := range spec.ShapeMasks {
+		images.Layers["mask."+name] = NewFramebuffer(imageSpec.Width, imageSpec.Height)
+	}
+	for name := range spec.MaterialMasks {
+		images.Layers["mask."+name] = NewFramebuffer(imageSpec.Width, imageSpec.Height)
+	}
+	shapeMasks := map[string]map[int]bool{}
+	for name, maskShapes := range spec.ShapeMasks {
+		shapeMasks[name] = map[int]bool{}
+		for _, maskShape := range maskShapes {
+			for i, s := range scene.Shapes {
+				if s == maskShape {
+					shapeMasks[name][i+1] = true
+				}
+			}
+		}
+	}
+
+	renderer := aovRenderer{imageSpec: &imageSpec, spec: &spec, camera: cam, frame: frame, shapeMasks: shapeMasks, images: images}
+	tiles := imageSpec.tiles()
+	jobs := make(chan tile, len(tiles))
+	for _, t := range tiles {
+		jobs <- t
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	for w := 0; w < imageSpec.WorkerCount; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			traceFunction := bvh.getTraceFunction(imageSpec.BvhTraversalAlgorithm)
+			sampler := newSampler(imageSpec.Sampler, imageSpec.AntiAliasingFactor)
+			materialColors := map[Material]r3.Vec{}
+			for t := range jobs {
+				for y := t.y0; y < t.y1; y++ {
+					for x := t.x0; x < t.x1; x++ {
+						renderer.tracePixel(traceFunction, newPixelSamples(sampler, imageSpec.Seed, x, y), materialColors, x, y)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return images
+}
+
+type aovRenderer struct {
+	imageSpec  *ImageSpec
+	spec       *AOVSpec
+	camera     camera
+	frame      cameraFrame
+	shapeMasks map[string]map[int]bool // name of the mask to the shape IDs it covers
+	images     *AOVImages
+}
+
+// each worker sets only the pixels of its own tiles, materialColors caches the material IDs of the worker
+func (ar *aovRenderer) tracePixel(traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), samples *pixelSamples, materialColors map[Material]r3.Vec, x int, y int) {
+	is := ar.imageSpec
+	layers := map[string]r3.Vec{}
+	add := func(name string, c r3.Vec) { layers[name] = r3.Add(layers[name], c) }
+	hits := 0
+	for s := 0; s < is.AntiAliasingFactor; s++ {
+		samples.startSample(s)
+		dx, dy := samples.get2D()
+		r := ar.camera.getRay((float64(x)+dx)/float64(is.Width), (float64(is.Height-1-y)+dy)/float64(is.Height), samples)
+		hit, hr := traceFunction(&r, 0)
+		if !hit {
+			continue
+		}
+		hits++
+		normal := r3.Unit(hr.normal)
+		if r3.Dot(normal, r.normalizedDirection) > 0 {
+			// facing the camera, like the side of the surface that is seen
+			normal = r3.Scale(-1, normal)
+		}
+		for _, aov := range ar.spec.AOVs {
+			switch aov {
+			case DepthAOV:
+				add("depth", r3.Vec{X: hr.t, Y: hr.t, Z: hr.t})
+			case NormalAOV:
+				add("normal", normal)
+			case CameraNormalAOV:
+				add("cameraNormal", r3.Vec{X: r3.Dot(normal, ar.frame.u), Y: r3.Dot(normal, ar.frame.v), Z: r3.Dot(normal, ar.frame.w)})
+			case AlbedoAOV:
+				if hr.material != nil {
+					add("albedo", hr.material.albedoColor(hr))
+				}
+			case PositionAOV:
+				add("position", hr.p)
+			case ShapeIDAOV:
+				add("shapeID", idColor(uint64(hr.shapeID)))
+			case MaterialIDAOV:
+				if hr.material == nil {
+					continue
+				}
+				c, ok := materialColors[hr.material]
+				if !ok {
+					// from the type and values of the material, textures show up as pointers
+					h := fnv.New64a()
+					fmt.Fprintf(h, "%T %v", hr.material, hr.material)
+					c = idColor(h.Sum64())
+					materialColors[hr.material] = c
+				}
+				add("materialID", c)
+			}
+		}
+		for name, ids := range ar.shapeMasks {
+			if ids[hr.shapeID] {
+				add("mask."+name, r3.Vec{X: 1, Y: 1, Z: 1})
+			}
+		}
+		for name, materials := range ar.spec.MaterialMasks {
+			for _, m := range materials {
+				if hr.material == m {
+					add("mask."+name, r3.Vec{X: 1, Y: 1, Z: 1})
+					break
+				}
+			}
+		}
+	}
+	for name, c := range layers {
+		// depth and position are averaged over the rays that hit, blending them with the background means nothing
+		n := is.AntiAliasingFactor
+		if name == "depth" || name == "position" {
+			n = hits
+		}
+		ar.images.Layers[name].Set(x, y, r3.Scale(1/float64(n), c))
+	}
+}
+
+// bright color for an ID, the same for the same ID
+func idColor(id uint64) r3.Vec {
+	return r3.Vec{X: 0.2 + 0.8*hashFloat64(id, 0), Y: 0.2 + 0.8*hashFloat64(id, 1), Z: 0.2 + 0.8*hashFloat64(id, 2)}
+}
+
+// image of a layer to look at, normals are mapped from [-1, 1] and depth and position are scaled down by their largest value
+func (images *AOVImages) ToRGBA(layer string) *image.RGBA {
+	fb, ok := images.Layers[layer]
+	if !ok {
+		panic(fmt.Sprintf("No AOV layer found for %s", layer))
+	}
+	scaled := NewFramebuffer(fb.Width, fb.Height)
+	switch layer {
+	case "normal", "cameraNormal":
+		for i, c := range fb.Pixels {
+			scaled.Pixels[i] = r3.Add(r3.Scale(0.5, c), r3.Vec{X: 0.5, Y: 0.5, Z: 0.5})
+		}
+	case "depth", "position":
+		largest := 0.0
+		for _, c := range fb.Pixels {
+			largest = math.Max(largest, math.Max(math.Abs(c.X), math.Max(math.Abs(c.Y), math.Abs(c.Z))))
+		}
+		for i, c := range fb.Pixels {
+			if largest > 0 {
+				c = r3.Scale(1/largest, c)
+			}
+			if layer == "position" {
+				c = r3.Add(r3.Scale(0.5, c), r3.Vec{X: 0.5, Y: 0.5, Z: 0.5})
+			}
+			scaled.Pixels[i] = c
+		}
+	default:
+		copy(scaled.Pixels, fb.Pixels)
+	}
+	return scaled.ToRGBA()
+}
+
+// writes the image and its AOVs as the layers of one OpenEXR image, depth is the single Z channel compositors look for
+// the AOVs have to be rendered at the size of the image
+func WriteLayeredEXR(file io.Writer, fb *Framebuffer, images *AOVImages) error {
+	channels := fb.exrChannels("")
+	names := make([]string, 0, len(images.Layers))
+	for name, layer := range images.Layers {
+		if layer.Width != fb.Width || layer.Height != fb.Height {
+			return fmt.Errorf("AOV layer %s is %dx%d, the image is %dx%d", name, layer.Width, layer.Height, fb.Width, fb.Height)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		layer := images.Layers[name]
+		if name == "depth" {
+			z := exrChannel{name: "Z", values: make([]float32, len(layer.Pixels))}
+			for i, c := range layer.Pixels {
+				z.values[i] = float32(c.X)
+			}
+			channels = append(channels, z)
+			continue
+		}
+		channels = append(channels, layer.exrChannels(name)...)
+	}
+	return writeEXR(file, fb.Width, fb.Height, channels)
+}
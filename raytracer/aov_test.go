@@ -0,0 +1,96 @@
+package raytracer
+
+import (
+	"bytes"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestRenderAOVs(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	red := PhongBlinn{ColorFrac: r3.Vec{X: 1}, SpecularColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}, SpecHardness: 20}
+	var images *AOVImages
+	withoutStdout(t, func() {
+		images = RenderAOVs(imageSpec, scene, AOVSpec{
+			AOVs:          []AOV{DepthAOV, NormalAOV, CameraNormalAOV, AlbedoAOV, PositionAOV, ShapeIDAOV, MaterialIDAOV},
+			ShapeMasks:    map[string][]Shape{"glass": {scene.Shapes[2]}},
+			MaterialMasks: map[string][]Material{"red": {red}},
+		})
+	})
+
+	// the glass sphere is in the middle of the image, 1 in front of its center
+	center := r3.Vec{}
+	distance := r3.Norm(r3.Sub(scene.CameraLookFrom, center)) - 1
+	if d := images.Layers["depth"].At(20, 15).X; math.Abs(d-distance) > 0.1 {
+		t.Errorf("expected the depth at the center to be about %f, got %f", distance, d)
+	}
+	if p := images.Layers["position"].At(20, 15); r3.Norm(r3.Sub(p, center)) > 1.01 {
+		t.Errorf("expected the position at the center to be on the glass sphere, got %v", p)
+	}
+	if n := images.Layers["cameraNormal"].At(20, 15); n.Z < 0.9 {
+		t.Errorf("expected the normal at the center to face the camera, got %v", n)
+	}
+	if n := images.Layers["normal"].At(20, 15); math.Abs(r3.Norm(n)-1) > 0.01 {
+		t.Errorf("expected a unit normal, got %v", n)
+	}
+	if a := images.Layers["albedo"].At(20, 15); a != (r3.Vec{X: 1, Y: 1, Z: 1}) {
+		t.Errorf("expected the albedo of glass to be white, got %v", a)
+	}
+	if m := images.Layers["mask.glass"].At(20, 15); m.X != 1 {
+		t.Errorf("expected the glass mask to cover the center, got %v", m)
+	}
+	if m := images.Layers["mask.glass"].At(0, 0); m.X != 0 {
+		t.Errorf("expected the glass mask to be empty in the corner, got %v", m)
+	}
+
+	// the red sphere is left of the center, the camera looks down +z
+	x := 20 - 9
+	if m := images.Layers["mask.red"].At(x, 15); m.X != 1 {
+		t.Errorf("expected the red mask to cover the red sphere, got %v", m)
+	}
+	if images.Layers["shapeID"].At(x, 15) == images.Layers["shapeID"].At(20, 15) {
+		t.Errorf("expected different shapes to get different IDs")
+	}
+	if images.Layers["materialID"].At(x, 15) == images.Layers["materialID"].At(20, 15) {
+		t.Errorf("expected different materials to get different IDs")
+	}
+
+	// anti aliased masks are between 0 and 1 along the edges
+	edge := false
+	for _, c := range images.Layers["mask.glass"].Pixels {
+		edge = edge || (c.X > 0 && c.X < 1)
+	}
+	if !edge {
+		t.Errorf("expected the edge of the glass mask to be anti aliased")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLayeredEXR(&buf, NewFramebuffer(40, 30), images); err != nil {
+		t.Fatal(err)
+	}
+	for _, channel := range []string{"R", "Z", "albedo.G", "mask.glass.R", "normal.R"} {
+		if !bytes.Contains(buf.Bytes(), []byte("\x00"+channel+"\x00")) {
+			t.Errorf("expected a %s channel", channel)
+		}
+	}
+	if err := WriteLayeredEXR(&buf, NewFramebuffer(80, 60), images); err == nil {
+		t.Errorf("expected an error writing AOVs of another size than the image")
+	}
+
+	// depth is scaled down by the farthest hit, rays that hit nothing stay black
+	img := images.ToRGBA("depth")
+	largest := uint8(0)
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > largest {
+			largest = img.Pix[i]
+		}
+	}
+	if c := img.RGBAAt(20, 15); largest != 255 || c.R == 0 || c.R == 255 {
+		t.Errorf("expected the farthest depth to be white and the center grey, got %d and %v", largest, c)
+	}
+	if images.Layers["depth"].At(0, 0).X == 0 && img.RGBAAt(0, 0).R != 0 {
+		t.Errorf("expected no depth to be black, got %v", img.RGBAAt(0, 0))
+	}
+}
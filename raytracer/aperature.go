@@ -0,0 +1,101 @@
+package raytracer
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// shape of the opening of the lens, out of focus highlights (bokeh) take this shape
+type Aperature interface {
+	// point on the opening inside of the unit disk, for a point u, v in the unit square
+	// points spread out evenly over the square are spread out evenly over the opening
+	sample(u float64, v float64) (x, y float64)
+}
+
+type CircularAperature struct{}
+
+// opening formed by the blades of a lens diaphragm, eg 6 blades gives hexagonal bokeh
+type PolygonalAperature struct {
+	Blades   int
+	Rotation float64 // in degrees
+}
+
+// opening with the shape of an image, brighter pixels let through more light, eg a star or heart cut out
+type ImageAperature struct {
+	width  int
+	height int
+	cdf    []float64 // cumulative brightness of the pixels, to pick pixels in proportion to their brightness
+}
+
+func NewImageAperature(img *image.RGBA) *ImageAperature {
+	bounds := img.Bounds()
+	a := ImageAperature{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		cdf:    make([]float64, 0, bounds.Dx()*bounds.Dy()),
+	}
+	total := 0.0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			total += 0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)
+			a.cdf = append(a.cdf, total)
+		}
+	}
+	if total == 0 {
+		panic("Aperature image is completely black")
+	}
+	return &a
+}
+
+// concentric mapping of the square onto the disk, see Shirley and Chiu, A Low Distortion Map Between Disk and Square
+func (c CircularAperature) sample(u float64, v float64) (x, y float64) {
+	a, b := 2*u-1, 2*v-1
+	if a == 0 && b == 0 {
+		return 0, 0
+	}
+	if math.Abs(a) > math.Abs(b) {
+		r, theta := a, math.Pi/4*(b/a)
+		return r * math.Cos(theta), r * math.Sin(theta)
+	}
+	r, theta := b, math.Pi/2-math.Pi/4*(a/b)
+	return r * math.Cos(theta), r * math.Sin(theta)
+}
+
+func (p PolygonalAperature) sample(u float64, v float64) (x, y float64) {
+	if p.Blades < 3 {
+		return CircularAperature{}.sample(u, v)
+	}
+	// pick one of the triangles between the center and an edge, they all have the same area
+	// what is left of u after picking the triangle is used for the point in it
+	blade := int(math.Min(float64(p.Blades-1), u*float64(p.Blades)))
+	u = u*float64(p.Blades) - float64(blade)
+	angle := 2 * math.Pi / float64(p.Blades)
+	start := p.Rotation*math.Pi/180 + float64(blade)*angle
+	x1, y1 := math.Cos(start), math.Sin(start)
+	x2, y2 := math.Cos(start+angle), math.Sin(start+angle)
+
+	// uniform point in the triangle
+	a, b := u, v
+	if a+b > 1 {
+		a, b = 1-a, 1-b
+	}
+	return a*x1 + b*x2, a*y1 + b*y2
+}
+
+func (i ImageAperature) sample(u float64, v float64) (x, y float64) {
+	target := u * i.cdf[len(i.cdf)-1]
+	pixel := sort.Search(len(i.cdf), func(k int) bool { return i.cdf[k] > target })
+	// where u falls in the brightness of the pixel picks the position across it
+	start := 0.0
+	if pixel > 0 {
+		start = i.cdf[pixel-1]
+	}
+	px := float64(pixel%i.width) + (target-start)/(i.cdf[pixel]-start)
+	py := float64(pixel/i.width) + v
+
+	// fit the longest side of the image to the unit disk, with y pointing up
+	size := math.Sqrt2 / 2 * math.Max(float64(i.width), float64(i.height))
+	return (px - float64(i.width)/2) / size, (float64(i.height)/2 - py) / size
+}
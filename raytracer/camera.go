@@ -3,53 +3,86 @@ package raytracer
 import (
 	"gonum.org/v1/gonum/spatial/r3"
 	"math"
-	"math/rand"
 )
 
-type camera struct {
-	w, u, v         r3.Vec
-	origin          r3.Vec
+// turns a point on the image into a ray, s and t are between [0, 1] from the bottom left of the image
+type camera interface {
+	getRay(s float64, t float64, samples *pixelSamples) ray
+	setShutter(open float64, close float64)
+}
+
+// position, orientation and shutter shared by all of the projections
+// w points backwards from the look at point, u to the right and v up
+type cameraFrame struct {
+	w, u, v      r3.Vec
+	origin       r3.Vec
+	shutterOpen  float64
+	shutterClose float64
+}
+
+// thin lens camera, points on the focus plane are sharp and everything else is blurred by the aperature
+type perspectiveCamera struct {
+	cameraFrame
 	lowerLeftCorner r3.Vec
 	horizontal      r3.Vec
 	vertical        r3.Vec
 	lensRadius      float64
+	aperature       Aperature
 }
 
-func NewCamera(lookFrom r3.Vec, lookAt r3.Vec, up r3.Vec, fov float64, aspect float64, aperature float64, focusDist float64) camera {
-	theta := fov * math.Pi / 180.0
-	halfHeight := math.Tan(theta / 2)
-	halfWidth := aspect * halfHeight
+func newCameraFrame(lookFrom r3.Vec, lookAt r3.Vec, up r3.Vec) cameraFrame {
 	w := r3.Unit(r3.Sub(lookFrom, lookAt))
 	u := r3.Unit(r3.Cross(up, w))
 	v := r3.Cross(w, u)
-	return camera{
-		w:               w,
-		u:               u,
-		v:               v,
-		origin:          lookFrom,
+	return cameraFrame{w: w, u: u, v: v, origin: lookFrom}
+}
+
+func NewCamera(lookFrom r3.Vec, lookAt r3.Vec, up r3.Vec, fov float64, aspect float64, aperature float64, focusDist float64) perspectiveCamera {
+	theta := fov * math.Pi / 180.0
+	halfHeight := math.Tan(theta / 2)
+	halfWidth := aspect * halfHeight
+	frame := newCameraFrame(lookFrom, lookAt, up)
+	u, v, w := frame.u, frame.v, frame.w
+	return perspectiveCamera{
+		cameraFrame:     frame,
 		lowerLeftCorner: r3.Sub(r3.Sub(r3.Sub(lookFrom, r3.Scale(halfWidth*focusDist, u)), r3.Scale(halfHeight*focusDist, v)), r3.Scale(focusDist, w)),
 		horizontal:      r3.Scale(2*halfWidth*focusDist, u),
 		vertical:        r3.Scale(2*halfHeight*focusDist, v),
 		lensRadius:      aperature / 2,
+		aperature:       CircularAperature{},
 	}
 }
 
-func (c camera) getRay(s float64, t float64) ray {
-	rd := r3.Scale(c.lensRadius, randomInUnitDisk())
+func (c perspectiveCamera) getRay(s float64, t float64, samples *pixelSamples) ray {
+	x, y := 0.0, 0.0
+	if c.lensRadius > 0 {
+		x, y = c.aperature.sample(samples.get2D())
+	}
+	rd := r3.Scale(c.lensRadius, r3.Vec{X: x, Y: y})
 	offset := r3.Add(r3.Scale(rd.X, c.u), r3.Scale(rd.Y, c.v))
 	return ray{
 		p:                   r3.Add(c.origin, offset),
 		normalizedDirection: r3.Unit(r3.Sub(r3.Sub(r3.Add(r3.Add(c.lowerLeftCorner, r3.Scale(s, c.horizontal)), r3.Scale(t, c.vertical)), c.origin), offset)),
+		time:                c.rayTime(samples),
+		samples:             samples,
+	}
+}
+
+// rays are cast at random times while the shutter is open, blurring shapes that move
+func (f *cameraFrame) setShutter(open float64, close float64) {
+	f.shutterOpen = open
+	f.shutterClose = close
+}
+
+func (f cameraFrame) rayTime(samples *pixelSamples) float64 {
+	if f.shutterClose > f.shutterOpen {
+		return f.shutterOpen + samples.get1D()*(f.shutterClose-f.shutterOpen)
 	}
+	return f.shutterOpen
 }
 
-func randomInUnitDisk() r3.Vec {
-	p := r3.Vec{}
-	for {
-		p = r3.Sub(r3.Scale(2, r3.Vec{X: rand.Float64(), Y: rand.Float64(), Z: 0}), r3.Vec{X: 1, Y: 1, Z: 0})
-		if r3.Dot(p, p) < 1.0 {
-			break
-		}
+func (c *perspectiveCamera) setAperature(a Aperature) {
+	if a != nil {
+		c.aperature = a
 	}
-	return p
 }
@@ -0,0 +1,86 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCameraFocusDistance(t *testing.T) {
+	scene := Scene{CameraLookFrom: r3.Vec{Z: -5}, CameraLookAt: r3.Vec{}}
+	if d := scene.focusDistance(); math.Abs(d-5) > 1e-9 {
+		t.Errorf("expected to focus on the look at point 5 away, got %f", d)
+	}
+	// off to the side, so in focus on the plane 3 in front of the camera
+	scene.CameraFocusPoint = &r3.Vec{X: 4, Z: -2}
+	if d := scene.focusDistance(); math.Abs(d-3) > 1e-9 {
+		t.Errorf("expected to focus 3 away, got %f", d)
+	}
+	// a point at the world origin is a point like any other
+	scene.CameraLookAt = r3.Vec{Z: 5}
+	scene.CameraFocusPoint = &r3.Vec{}
+	if d := scene.focusDistance(); math.Abs(d-5) > 1e-9 {
+		t.Errorf("expected to focus on the origin 5 away, got %f", d)
+	}
+	// behind the camera is an error when validating, and the look at point is used when rendering anyway
+	scene.CameraFocusPoint = &r3.Vec{Z: -8}
+	if err := scene.validate(); err == nil {
+		t.Errorf("expected an error for a focus point behind the camera")
+	}
+	if d := scene.focusDistance(); math.Abs(d-10) > 1e-9 {
+		t.Errorf("expected to focus on the look at point 10 away, got %f", d)
+	}
+	scene.CameraLookAt = r3.Vec{}
+	scene.CameraFocusDistance = 7
+	if d := scene.focusDistance(); d != 7 {
+		t.Errorf("expected the focus distance to be used, got %f", d)
+	}
+
+	// rays through the same point of the image with different lens samples meet on the focus plane
+	cam := NewCamera(scene.CameraLookFrom, scene.CameraLookAt, r3.Vec{Y: 1}, 60, 1, 1, 7)
+	cam.setAperature(PolygonalAperature{Blades: 6})
+	first := cam.getRay(0.3, 0.6, nil)
+	for i := 0; i < 10; i++ {
+		r := cam.getRay(0.3, 0.6, nil)
+		p := r.PointAtT((2 - r.p.Z) / r.normalizedDirection.Z)
+		expected := first.PointAtT((2 - first.p.Z) / first.normalizedDirection.Z)
+		if r3.Norm(r3.Sub(p, expected)) > 1e-9 {
+			t.Fatalf("expected rays to meet at %v on the focus plane, got %v", expected, p)
+		}
+	}
+}
+
+func TestAperatureSamples(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if x, y := (CircularAperature{}).sample(rand.Float64(), rand.Float64()); x*x+y*y > 1+1e-9 {
+			t.Fatalf("expected sample inside of the unit disk, got (%f, %f)", x, y)
+		}
+	}
+
+	// a hexagon with a vertex on the x axis reaches x=1 but only y=sqrt(3)/2
+	hexagon := PolygonalAperature{Blades: 6}
+	maxY := 0.0
+	for i := 0; i < 10000; i++ {
+		x, y := hexagon.sample(rand.Float64(), rand.Float64())
+		if x*x+y*y > 1+1e-9 {
+			t.Fatalf("expected sample inside of the unit disk, got (%f, %f)", x, y)
+		}
+		maxY = math.Max(maxY, y)
+	}
+	if maxY > math.Sqrt(3)/2+1e-9 || maxY < math.Sqrt(3)/2-0.05 {
+		t.Errorf("expected hexagon samples to reach y=%f, got %f", math.Sqrt(3)/2, maxY)
+	}
+
+	// only the top right pixel of the image is lit
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Pix[4], img.Pix[5], img.Pix[6], img.Pix[7] = 255, 255, 255, 255
+	aperature := NewImageAperature(img)
+	for i := 0; i < 1000; i++ {
+		x, y := aperature.sample(rand.Float64(), rand.Float64())
+		if x < 0 || y < 0 || x*x+y*y > 1+1e-9 {
+			t.Fatalf("expected sample in the top right of the unit disk, got (%f, %f)", x, y)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package raytracer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"hash/fnv"
+	"image"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	checkpointMagic = "RTCP"
+	// largest image a checkpoint is read for, pixels are 28 bytes each in the file
+	maxCheckpointPixels = 1 << 28
+)
+
+// state of a render that was stopped part way, every sample's random numbers follow from the seed, the sampler and
+// the index of the sample, so with the samples taken of every pixel it is all that is needed to carry on
+// the tiles, crop and adaptive sampling decide which pixels were rendered together, so they have to match too,
+// and the scene has to be the same so the pixels already rendered are still right
+type Checkpoint struct {
+	Seed               int64
+	Sampler            SamplerType
+	AntiAliasingFactor int
+	TileSize           int
+	TileOrder          TileOrder
+	Crop               image.Rectangle   // pixels that are traced, the whole image when there is no crop
+	AdaptiveSampling   *AdaptiveSampling // nil without adaptive sampling
+	SceneHash          uint64            // fnv hash of the scene as written by WriteScene
+	Framebuffer        *Framebuffer
+}
+
+// scenes that can't be written (eg with SDFShapes) can't be hashed
+func hashScene(scene *Scene) (uint64, error) {
+	data, err := encodeGob(scene)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64(), nil
+}
+
+// checkpoint of the render of the image spec and the scene with the hash into the framebuffer
+func newCheckpoint(is *ImageSpec, sceneHash uint64, fb *Framebuffer) *Checkpoint {
+	tileSize := is.TileSize
+	if tileSize <= 0 {
+		tileSize = defaultTileSize
+	}
+	return &Checkpoint{
+		Seed:               is.Seed,
+		Sampler:            is.Sampler,
+		AntiAliasingFactor: is.AntiAliasingFactor,
+		TileSize:           tileSize,
+		TileOrder:          is.TileOrder,
+		Crop:               is.cropRectangle(),
+		AdaptiveSampling:   is.AdaptiveSampling,
+		SceneHash:          sceneHash,
+		Framebuffer:        fb,
+	}
+}
+
+// where and how often GenerateImageWithCheckpoints writes checkpoints
+type CheckpointSpec struct {
+	FileName string
+	Interval time.Duration // zero writes a checkpoint after every tile
+}
+
+// renders like GenerateImage, resuming from the checkpoint file when there is one and writing it every interval
+// and when the image is finished, a resumed render gives the same image as one that was never stopped
+// the scene has to be one WriteScene can write, so a checkpoint of another scene is recognised
+func GenerateImageWithCheckpoints(imageSpec ImageSpec, scene Scene, spec CheckpointSpec) (*image.RGBA, error) {
+	sceneHash, err := hashScene(&scene)
+	if err != nil {
+		return nil, err
+	}
+	fb := NewFramebuffer(imageSpec.Width, imageSpec.Height)
+	resumed := false
+	if file, err := os.Open(spec.FileName); err == nil {
+		checkpoint, err := LoadCheckpoint(bufio.NewReader(file))
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkpoint.matches(&imageSpec, sceneHash); err != nil {
+			return nil, err
+		}
+		fb = checkpoint.Framebuffer
+		resumed = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	tiles := []tile{}
+	for _, t := range imageSpec.tiles() {
+		if !fb.rendered(t) {
+			tiles = append(tiles, t)
+		}
+	}
+	if resumed && len(tiles) > 0 {
+		fmt.Printf("Resuming with %d tiles left to render\n", len(tiles))
+	}
+
+	checkpoint := newCheckpoint(&imageSpec, sceneHash, fb)
+	lock := sync.Mutex{}
+	var writeErr error
+	lastWrite := time.Now()
+	tileDone := func() {
+		if writeErr != nil || time.Since(lastWrite) < spec.Interval {
+			return
+		}
+		lock.Lock()
+		writeErr = writeCheckpointFile(spec.FileName, checkpoint)
+		lock.Unlock()
+		lastWrite = time.Now()
+	}
+
+	shapes := scene.renderShapes()
+	bvh := NewBoundingVolumeHierarchy(&shapes)
+	cam := scene.camera(float64(imageSpec.Width) / float64(imageSpec.Height))
+	renderPass(imageSpec, scene, cam, bvh, fb, &lock, tiles, tileDone)
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	if err := writeCheckpointFile(spec.FileName, checkpoint); err != nil {
+		return nil, err
+	}
+	return imageSpec.output(fb), nil
+}
+
+// tiles are added to the framebuffer all at once, so a tile is done when all of its pixels have samples
+func (fb *Framebuffer) rendered(t tile) bool {
+	for y := t.y0; y < t.y1; y++ {
+		for x := t.x0; x < t.x1; x++ {
+			if fb.Samples[y*fb.Width+x] == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *Checkpoint) matches(is *ImageSpec, sceneHash uint64) error {
+	// the framebuffer is only there for its size
+	expected := newCheckpoint(is, sceneHash, &Framebuffer{Width: is.Width, Height: is.Height}).settings()
+	if settings := c.settings(); settings != expected {
+		return fmt.Errorf("checkpoint of a %s can't be resumed as a %s", settings, expected)
+	}
+	return nil
+}
+
+// everything but the pixels, so checkpoints of the same render have the same settings
+func (c *Checkpoint) settings() string {
+	adaptiveSampling := "none"
+	if c.AdaptiveSampling != nil {
+		adaptiveSampling = fmt.Sprintf("%+v", *c.AdaptiveSampling)
+	}
+	return fmt.Sprintf("%dx%d image of scene %016x with seed %d, sampler %d, %d samples, %d pixel tiles in order %d, crop %v and adaptive sampling %s",
+		c.Framebuffer.Width, c.Framebuffer.Height, c.SceneHash, c.Seed, c.Sampler, c.AntiAliasingFactor, c.TileSize, c.TileOrder, c.Crop, adaptiveSampling)
+}
+
+// written next to the checkpoint and then renamed over it, so a render stopped while writing keeps the last checkpoint
+func writeCheckpointFile(fileName string, c *Checkpoint) error {
+	file, err := os.Create(fileName + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := WriteCheckpoint(writer, c); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fileName+".tmp", fileName)
+}
+
+type checkpointHeader struct {
+	Width, Height      uint32
+	Seed               int64
+	Sampler            uint32
+	AntiAliasingFactor uint32
+	TileSize           uint32
+	TileOrder          uint32
+	Crop               [4]int32
+	NoiseThreshold     float64
+	MaxSamples         uint32
+	SceneHash          uint64
+}
+
+type checkpointPixel struct {
+	Color   [3]float64
+	Samples uint32
+}
+
+// reads a checkpoint stored little endian as:
+// "RTCP", width and height as uint32, seed as int64, sampler, anti aliasing factor, tile size and tile order as uint32,
+// the crop rectangle as min x, min y, max x and max y int32, the noise threshold as float64 and max samples as uint32
+// of adaptive sampling (0 without it), the scene hash as uint64, then for each pixel from the top left its color as 3 float64 followed by its
+// samples as uint32
+func LoadCheckpoint(file io.Reader) (*Checkpoint, error) {
+	if err := readMagic(file, checkpointMagic); err != nil {
+		return nil, err
+	}
+	var header checkpointHeader
+	if err := binary.Read(file, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	pixels := uint64(header.Width) * uint64(header.Height)
+	if pixels > maxCheckpointPixels {
+		return nil, fmt.Errorf("checkpoint of a %dx%d image is larger than the %d pixels that can be read", header.Width, header.Height, maxCheckpointPixels)
+	}
+	// read in chunks, so a header claiming more pixels than the file has fails when they run out, not by allocating them
+	fb := &Framebuffer{Width: int(header.Width), Height: int(header.Height)}
+	chunk := make([]checkpointPixel, 4096)
+	for remaining := int(pixels); remaining > 0; remaining -= len(chunk) {
+		if remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+		if err := binary.Read(file, binary.LittleEndian, chunk); err != nil {
+			return nil, err
+		}
+		for _, pixel := range chunk {
+			fb.Pixels = append(fb.Pixels, r3.Vec{X: pixel.Color[0], Y: pixel.Color[1], Z: pixel.Color[2]})
+			fb.Samples = append(fb.Samples, int(pixel.Samples))
+		}
+	}
+	c := &Checkpoint{
+		Seed:               header.Seed,
+		Sampler:            SamplerType(header.Sampler),
+		AntiAliasingFactor: int(header.AntiAliasingFactor),
+		TileSize:           int(header.TileSize),
+		TileOrder:          TileOrder(header.TileOrder),
+		Crop:               image.Rect(int(header.Crop[0]), int(header.Crop[1]), int(header.Crop[2]), int(header.Crop[3])),
+		SceneHash:          header.SceneHash,
+		Framebuffer:        fb,
+	}
+	if header.MaxSamples > 0 {
+		c.AdaptiveSampling = &AdaptiveSampling{NoiseThreshold: header.NoiseThreshold, MaxSamples: int(header.MaxSamples)}
+	}
+	return c, nil
+}
+
+func WriteCheckpoint(file io.Writer, c *Checkpoint) error {
+	if _, err := file.Write([]byte(checkpointMagic)); err != nil {
+		return err
+	}
+	header := checkpointHeader{
+		Width:              uint32(c.Framebuffer.Width),
+		Height:             uint32(c.Framebuffer.Height),
+		Seed:               c.Seed,
+		Sampler:            uint32(c.Sampler),
+		AntiAliasingFactor: uint32(c.AntiAliasingFactor),
+		TileSize:           uint32(c.TileSize),
+		TileOrder:          uint32(c.TileOrder),
+		Crop:               [4]int32{int32(c.Crop.Min.X), int32(c.Crop.Min.Y), int32(c.Crop.Max.X), int32(c.Crop.Max.Y)},
+		SceneHash:          c.SceneHash,
+	}
+	if c.AdaptiveSampling != nil {
+		header.NoiseThreshold, header.MaxSamples = c.AdaptiveSampling.NoiseThreshold, uint32(c.AdaptiveSampling.MaxSamples)
+	}
+	if err := binary.Write(file, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	for i, p := range c.Framebuffer.Pixels {
+		pixel := checkpointPixel{Color: [3]float64{p.X, p.Y, p.Z}, Samples: uint32(c.Framebuffer.Samples[i])}
+		if err := binary.Write(file, binary.LittleEndian, pixel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	fb := NewFramebuffer(3, 2)
+	c := r3.Vec{X: 0.25, Y: 0.5, Z: 2}
+	fb.addSamples(1, 1, c, 4)
+	checkpoint := Checkpoint{Seed: -3, Sampler: Halton, AntiAliasingFactor: 4, TileSize: 16, TileOrder: Hilbert, Crop: image.Rect(1, 0, 3, 2), AdaptiveSampling: &AdaptiveSampling{NoiseThreshold: 0.01, MaxSamples: 64}, SceneHash: 1 << 60, Framebuffer: fb}
+	var buf bytes.Buffer
+	if err := WriteCheckpoint(&buf, &checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCheckpoint(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.settings() != checkpoint.settings() || loaded.Framebuffer.At(1, 1) != c || loaded.Framebuffer.Samples[4] != 4 {
+		t.Errorf("expected the checkpoint to be read back the same, got %+v", loaded)
+	}
+	if _, err := LoadCheckpoint(bytes.NewReader([]byte("RTDG"))); err == nil {
+		t.Errorf("expected an error reading a checkpoint from another kind of file")
+	}
+
+	// a header claiming a huge image is turned away, or fails when the pixels run out, without allocating them
+	for _, size := range [][2]uint32{{1 << 20, 1 << 20}, {1 << 13, 1 << 13}} {
+		buf.Reset()
+		buf.WriteString(checkpointMagic)
+		if err := binary.Write(&buf, binary.LittleEndian, checkpointHeader{Width: size[0], Height: size[1]}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCheckpoint(&buf); err == nil {
+			t.Errorf("expected an error reading a %dx%d checkpoint without pixels", size[0], size[1])
+		}
+	}
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	imageSpec.TileSize = 8
+	imageSpec.Seed = 5
+	spec := CheckpointSpec{FileName: filepath.Join(t.TempDir(), "render.rtcp")}
+
+	withoutStdout(t, func() {
+		fb := RenderFramebuffer(imageSpec, scene)
+		expected := fb.ToRGBA()
+
+		// a render killed after the first few tiles
+		for i, tile := range imageTiles(imageSpec.Width, imageSpec.Height, imageSpec.TileSize, imageSpec.TileOrder) {
+			if i < 5 {
+				continue
+			}
+			for y := tile.y0; y < tile.y1; y++ {
+				for x := tile.x0; x < tile.x1; x++ {
+					fb.Set(x, y, r3.Vec{X: 1})
+					fb.Samples[y*fb.Width+x] = 0
+				}
+			}
+		}
+		sceneHash, err := hashScene(&scene)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeCheckpointFile(spec.FileName, newCheckpoint(&imageSpec, sceneHash, fb)); err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := GenerateImageWithCheckpoints(imageSpec, scene, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(img.Pix, expected.Pix) {
+			t.Errorf("expected the resumed render to be the same as the uninterrupted one")
+		}
+
+		// a checkpoint of another render is not resumed
+		for name, other := range map[string]func(is *ImageSpec){
+			"seed":              func(is *ImageSpec) { is.Seed = 6 },
+			"tile size":         func(is *ImageSpec) { is.TileSize = 16 },
+			"tile order":        func(is *ImageSpec) { is.TileOrder = Hilbert },
+			"crop":              func(is *ImageSpec) { is.Crop = image.Rect(0, 0, 20, 20) },
+			"adaptive sampling": func(is *ImageSpec) { is.AdaptiveSampling = &AdaptiveSampling{NoiseThreshold: 0.01, MaxSamples: 16} },
+		} {
+			otherSpec := imageSpec
+			other(&otherSpec)
+			if _, err := GenerateImageWithCheckpoints(otherSpec, scene, spec); err == nil {
+				t.Errorf("expected an error resuming with another %s", name)
+			}
+		}
+
+		// nor is a finished checkpoint of a scene that was changed since
+		changed := scene
+		changed.Shapes = append([]Shape{}, scene.Shapes...)
+		changed.Shapes[3] = &Sphere{Center: r3.Vec{X: 1.5, Y: 0.5}, Radius: 1, Mat: PhongBlinn{ColorFrac: r3.Vec{Z: 1}}}
+		if _, err := GenerateImageWithCheckpoints(imageSpec, changed, spec); err == nil {
+			t.Errorf("expected an error resuming with a changed shape")
+		}
+	})
+}
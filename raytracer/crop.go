@@ -0,0 +1,58 @@
+package raytracer
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+type CropOutput int
+
+const (
+	CropToRegion = iota // image of just the crop rectangle, its bounds are the rectangle so it can be drawn in place
+	FullFrame           // image of the full size, transparent outside of the crop rectangle
+)
+
+// pixels that are traced, the crop rectangle within the image or the whole image when there is none
+func (is *ImageSpec) cropRectangle() image.Rectangle {
+	full := image.Rect(0, 0, is.Width, is.Height)
+	if is.Crop.Empty() {
+		return full
+	}
+	return is.Crop.Intersect(full)
+}
+
+// tiles of the image cut down to the crop rectangle, the tiles line up with those of the whole image so renders of
+// different regions can be put together
+func (is *ImageSpec) tiles() []tile {
+	crop := is.cropRectangle()
+	tiles := []tile{}
+	for _, t := range imageTiles(is.Width, is.Height, is.TileSize, is.TileOrder) {
+		r := image.Rect(t.x0, t.y0, t.x1, t.y1).Intersect(crop)
+		if !r.Empty() {
+			tiles = append(tiles, tile{x0: r.Min.X, y0: r.Min.Y, x1: r.Max.X, y1: r.Max.Y})
+		}
+	}
+	return tiles
+}
+
+// image of the rendered pixels the way the crop output asks for
+func (is *ImageSpec) output(fb *Framebuffer) *image.RGBA {
+	img := fb.ToRGBA()
+	crop := is.cropRectangle()
+	if crop == img.Bounds() {
+		return img
+	}
+	switch is.CropOutput {
+	case CropToRegion:
+		region := image.NewRGBA(crop)
+		draw.Draw(region, crop, img, crop.Min, draw.Src)
+		return region
+	case FullFrame:
+		full := image.NewRGBA(img.Bounds())
+		draw.Draw(full, crop, img, crop.Min, draw.Src)
+		return full
+	default:
+		panic(fmt.Sprintf("No crop output found for %d", is.CropOutput))
+	}
+}
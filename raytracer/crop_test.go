@@ -0,0 +1,56 @@
+package raytracer
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestCropRegion(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	imageSpec.TileSize = 16
+	var full, region, frame *image.RGBA
+	withoutStdout(t, func() {
+		full = GenerateImage(imageSpec, scene)
+		imageSpec.Crop = image.Rect(10, 5, 35, 22)
+		region = GenerateImage(imageSpec, scene)
+		imageSpec.CropOutput = FullFrame
+		frame = GenerateImage(imageSpec, scene)
+	})
+
+	if region.Bounds() != imageSpec.Crop {
+		t.Fatalf("expected the cropped image to cover %v, got %v", imageSpec.Crop, region.Bounds())
+	}
+	if frame.Bounds() != full.Bounds() {
+		t.Fatalf("expected the full frame image to cover %v, got %v", full.Bounds(), frame.Bounds())
+	}
+	for y := 0; y < imageSpec.Height; y++ {
+		for x := 0; x < imageSpec.Width; x++ {
+			p := image.Pt(x, y)
+			if p.In(imageSpec.Crop) {
+				if region.RGBAAt(x, y) != full.RGBAAt(x, y) || frame.RGBAAt(x, y) != full.RGBAAt(x, y) {
+					t.Fatalf("expected pixel %v in the crop to match the full render", p)
+				}
+			} else if frame.RGBAAt(x, y).A != 0 {
+				t.Fatalf("expected pixel %v outside of the crop to be transparent", p)
+			}
+		}
+	}
+
+	// regions rendered separately, eg on different machines, put together into the full image
+	canvas := image.NewRGBA(full.Bounds())
+	withoutStdout(t, func() {
+		imageSpec.CropOutput = CropToRegion
+		for _, crop := range []image.Rectangle{image.Rect(0, 0, 40, 13), image.Rect(0, 13, 40, 30)} {
+			imageSpec.Crop = crop
+			region := GenerateImage(imageSpec, scene)
+			draw.Draw(canvas, region.Bounds(), region, region.Bounds().Min, draw.Src)
+		}
+	})
+	for i := range canvas.Pix {
+		if canvas.Pix[i] != full.Pix[i] {
+			t.Fatalf("expected the regions to put together the full image")
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+	"sort"
+)
+
+type CSGOperation int
+
+const (
+	Union = iota
+	Intersection
+	Difference
+)
+
+// closed shape that can report every span of a ray that is inside of it, used by constructive solid geometry
+type Solid interface {
+	Shape
+
+	// sorted, non overlapping spans of the ray inside the solid, including any behind the ray origin
+	intervals(r *ray) []solidInterval
+}
+
+type solidInterval struct {
+	enter hitRecord
+	exit  hitRecord
+}
+
+// combines two solids by union, intersection or difference (Left minus Right)
+type CSG struct {
+	Operation CSGOperation
+	Left      Solid
+	Right     Solid
+}
+
+// panics when an operand is not closed, eg an uncapped cylinder
+func NewCSG(operation CSGOperation, left Solid, right Solid) *CSG {
+	c := &CSG{Operation: operation, Left: left, Right: right}
+	if err := c.validate(); err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+// checks that both operands, and the operands of nested CSGs, are closed
+func (c CSG) validate() error {
+	for _, operand := range []Solid{c.Left, c.Right} {
+		if err := validateSolid(operand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSolid(solid Shape) error {
+	switch s := solid.(type) {
+	case *Cylinder:
+		if !s.Capped {
+			return fmt.Errorf("uncapped cylinder can't be a CSG operand: %s", s.description())
+		}
+	case *Cone:
+		if !s.Capped {
+			return fmt.Errorf("uncapped cone can't be a CSG operand: %s", s.description())
+		}
+	case *CSG:
+		return s.validate()
+	case *TransformedShape:
+		if _, ok := s.Shape.(Solid); !ok {
+			return fmt.Errorf("transformed shape that is not a solid can't be a CSG operand: %s", s.Shape.description())
+		}
+		return validateSolid(s.Shape)
+	}
+	return nil
+}
+
+// validates any CSG that is or is wrapped by the shape
+func validateCSGs(shape Shape) error {
+	switch s := shape.(type) {
+	case *CSG:
+		return s.validate()
+	case *TransformedShape:
+		return validateCSGs(s.Shape)
+	case *MovingShape:
+		return validateCSGs(s.Shape)
+	case *materialOverride:
+		return validateCSGs(s.Shape)
+	}
+	return nil
+}
+
+type csgEvent struct {
+	record  hitRecord
+	isLeft  bool
+	isEnter bool
+}
+
+func (c CSG) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	for _, interval := range c.intervals(r) {
+		if interval.enter.t > tMin && interval.enter.t <= tMax {
+			return interval.enter
+		}
+		if interval.exit.t > tMin && interval.exit.t <= tMax {
+			return interval.exit
+		}
+	}
+	return hitRecord{t: -1}
+}
+
+func (c CSG) intervals(r *ray) []solidInterval {
+	events := make([]csgEvent, 0)
+	for _, interval := range c.Left.intervals(r) {
+		events = append(events, csgEvent{record: interval.enter, isLeft: true, isEnter: true}, csgEvent{record: interval.exit, isLeft: true, isEnter: false})
+	}
+	for _, interval := range c.Right.intervals(r) {
+		events = append(events, csgEvent{record: interval.enter, isLeft: false, isEnter: true}, csgEvent{record: interval.exit, isLeft: false, isEnter: false})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].record.t < events[j].record.t
+	})
+
+	// sweep along the ray, keeping track of which solids we are inside of
+	res := make([]solidInterval, 0)
+	insideLeft, insideRight, inside := false, false, false
+	var enter hitRecord
+	for _, e := range events {
+		if e.isLeft {
+			insideLeft = e.isEnter
+		} else {
+			insideRight = e.isEnter
+		}
+		nowInside := c.combine(insideLeft, insideRight)
+		if nowInside == inside {
+			continue
+		}
+
+		record := e.record
+		// surfaces of the subtracted solid face into the result
+		if c.Operation == Difference && !e.isLeft {
+			record.normal = r3.Scale(-1, record.normal)
+		}
+		if nowInside {
+			enter = record
+		} else {
+			res = append(res, solidInterval{enter: enter, exit: record})
+		}
+		inside = nowInside
+	}
+	return res
+}
+
+func (c CSG) combine(insideLeft bool, insideRight bool) bool {
+	switch c.Operation {
+	case Union:
+		return insideLeft || insideRight
+	case Intersection:
+		return insideLeft && insideRight
+	case Difference:
+		return insideLeft && !insideRight
+	default:
+		panic(fmt.Sprintf("No CSG operation found for %d", c.Operation))
+	}
+}
+
+func (c *CSG) Translate(tv r3.Vec) {
+	c.Left.Translate(tv)
+	c.Right.Translate(tv)
+}
+
+// scales around the centroid of the combined solid, keeping both solids in the same place relative to each other
+func (c *CSG) Scale(f float64) {
+	center := c.centroid()
+	for _, s := range []Solid{c.Left, c.Right} {
+		offset := r3.Sub(s.centroid(), center)
+		s.Scale(f)
+		s.Translate(r3.Scale(f-1, offset))
+	}
+}
+
+func (c *CSG) Rotate(rv r3.Vec) {
+	c.Left.Rotate(rv)
+	c.Right.Rotate(rv)
+}
+
+func (c CSG) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	leftLowest, leftHighest := c.Left.computeSquareBounds()
+	rightLowest, rightHighest := c.Right.computeSquareBounds()
+	switch c.Operation {
+	case Union:
+		return r3.Vec{
+				X: math.Min(leftLowest.X, rightLowest.X),
+				Y: math.Min(leftLowest.Y, rightLowest.Y),
+				Z: math.Min(leftLowest.Z, rightLowest.Z),
+			}, r3.Vec{
+				X: math.Max(leftHighest.X, rightHighest.X),
+				Y: math.Max(leftHighest.Y, rightHighest.Y),
+				Z: math.Max(leftHighest.Z, rightHighest.Z),
+			}
+	case Intersection:
+		return r3.Vec{
+				X: math.Max(leftLowest.X, rightLowest.X),
+				Y: math.Max(leftLowest.Y, rightLowest.Y),
+				Z: math.Max(leftLowest.Z, rightLowest.Z),
+			}, r3.Vec{
+				X: math.Min(leftHighest.X, rightHighest.X),
+				Y: math.Min(leftHighest.Y, rightHighest.Y),
+				Z: math.Min(leftHighest.Z, rightHighest.Z),
+			}
+	default:
+		return leftLowest, leftHighest
+	}
+}
+
+func (c CSG) centroid() r3.Vec {
+	lowest, highest := c.computeSquareBounds()
+	return r3.Scale(0.5, r3.Add(lowest, highest))
+}
+
+// texture lookups happen on the solid that was hit, see intervals
+func (c CSG) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	return 0, 0
+}
+
+func (c CSG) description() string {
+	return fmt.Sprintf(
+		"%s - Operation: %d, Left: (%s), Right: (%s)",
+		reflect.TypeOf(c),
+		c.Operation,
+		c.Left.description(),
+		c.Right.description(),
+	)
+}
+
+func (s Sphere) intervals(r *ray) []solidInterval {
+	oc := r3.Sub(r.p, s.Center)
+	b := r3.Dot(oc, r.normalizedDirection)
+	c := r3.Dot(oc, oc) - s.Radius*s.Radius
+	discriminant := b*b - c
+	if discriminant <= 0 {
+		return nil
+	}
+	sqrtDiscriminant := math.Sqrt(discriminant)
+	return []solidInterval{{
+		enter: s.intervalHitRecord(r, -b-sqrtDiscriminant),
+		exit:  s.intervalHitRecord(r, -b+sqrtDiscriminant),
+	}}
+}
+
+func (s Sphere) intervalHitRecord(r *ray, t float64) hitRecord {
+	p := r.PointAtT(t)
+	return hitRecord{
+		t:        t,
+		p:        p,
+		normal:   r3.Scale(1/s.Radius, r3.Sub(p, s.Center)),
+		shape:    &s,
+		material: s.Mat,
+	}
+}
+
+func (b Box) intervals(r *ray) []solidInterval {
+	return surfaceHitIntervals(r, b.surfaceHits(r), &b, b.Mat)
+}
+
+func (ob OrientedBox) intervals(r *ray) []solidInterval {
+	return surfaceHitIntervals(r, ob.surfaceHits(r), &ob, ob.Mat)
+}
+
+// only closed when Capped
+func (cy Cylinder) intervals(r *ray) []solidInterval {
+	return surfaceHitIntervals(r, cy.surfaceHits(r), &cy, cy.Mat)
+}
+
+// only closed when Capped
+func (co Cone) intervals(r *ray) []solidInterval {
+	return surfaceHitIntervals(r, co.surfaceHits(r), &co, co.Mat)
+}
+
+func (to Torus) intervals(r *ray) []solidInterval {
+	return surfaceHitIntervals(r, to.surfaceHits(r), &to, to.Mat)
+}
+
+// returns no intervals when the transformed shape is not a Solid
+func (ts TransformedShape) intervals(r *ray) []solidInterval {
+	solid, ok := ts.Shape.(Solid)
+	if !ok {
+		return nil
+	}
+	objectRay, scale := transformRay(&ts.inverse, r)
+	res := solid.intervals(&objectRay)
+	for i := range res {
+		res[i].enter = transformHitRecord(r, &res[i].enter, scale, &ts.transform, &ts.inverse)
+		res[i].exit = transformHitRecord(r, &res[i].exit, scale, &ts.transform, &ts.inverse)
+	}
+	return res
+}
+
+// pairs up sorted surface crossings of a closed shape into spans of entering and exiting the shape
+func surfaceHitIntervals(r *ray, hits []surfaceHit, shape Shape, mat Material) []solidInterval {
+	res := make([]solidInterval, 0, len(hits)/2)
+	for i := 0; i+1 < len(hits); i += 2 {
+		res = append(res, solidInterval{
+			enter: hitRecord{t: hits[i].t, p: r.PointAtT(hits[i].t), normal: hits[i].normal, shape: shape, material: mat},
+			exit:  hitRecord{t: hits[i+1].t, p: r.PointAtT(hits[i+1].t), normal: hits[i+1].normal, shape: shape, material: mat},
+		})
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestCSGOperations(t *testing.T) {
+	// two unit spheres overlapping between x=-0.5 and x=0.5
+	newLeft := func() Solid { return &Sphere{Center: r3.Vec{X: -0.5}, Radius: 1} }
+	newRight := func() Solid { return &Sphere{Center: r3.Vec{X: 0.5}, Radius: 1} }
+	alongX := ray{p: r3.Vec{X: -10}, normalizedDirection: r3.Vec{X: 1}}
+
+	tests := []struct {
+		name              string
+		operation         CSGOperation
+		expectedIntervals [][2]float64
+		expectedNormal    r3.Vec
+	}{
+		{"union", Union, [][2]float64{{8.5, 11.5}}, r3.Vec{X: -1}},
+		{"intersection", Intersection, [][2]float64{{9.5, 10.5}}, r3.Vec{X: -1}},
+		{"difference", Difference, [][2]float64{{8.5, 9.5}}, r3.Vec{X: -1}},
+	}
+	for _, test := range tests {
+		c := CSG{Operation: test.operation, Left: newLeft(), Right: newRight()}
+		intervals := c.intervals(&alongX)
+		if len(intervals) != len(test.expectedIntervals) {
+			t.Fatalf("%s: expected %d intervals, got %d", test.name, len(test.expectedIntervals), len(intervals))
+		}
+		for i, interval := range intervals {
+			if math.Abs(interval.enter.t-test.expectedIntervals[i][0]) > 1e-9 || math.Abs(interval.exit.t-test.expectedIntervals[i][1]) > 1e-9 {
+				t.Errorf("%s: expected interval %v, got [%f, %f]", test.name, test.expectedIntervals[i], interval.enter.t, interval.exit.t)
+			}
+		}
+
+		hr := c.hit(&alongX, 0, math.MaxFloat64)
+		if math.Abs(hr.t-test.expectedIntervals[0][0]) > 1e-9 || r3.Norm(r3.Sub(hr.normal, test.expectedNormal)) > 1e-9 {
+			t.Errorf("%s: expected hit at t=%f with normal %v, got t=%f with normal %v", test.name, test.expectedIntervals[0][0], test.expectedNormal, hr.t, hr.normal)
+		}
+	}
+
+	// the exit of the difference is on the surface of the subtracted sphere, so its normal must face into the remaining solid
+	difference := CSG{Operation: Difference, Left: newLeft(), Right: newRight()}
+	hr := difference.hit(&alongX, 9, math.MaxFloat64)
+	if math.Abs(hr.t-9.5) > 1e-9 || r3.Norm(r3.Sub(hr.normal, r3.Vec{X: 1})) > 1e-9 {
+		t.Errorf("expected difference exit at t=9.5 with normal {1 0 0}, got t=%f with normal %v", hr.t, hr.normal)
+	}
+}
+
+func TestSolidIntervals(t *testing.T) {
+	alongX := ray{p: r3.Vec{X: -10}, normalizedDirection: r3.Vec{X: 1}}
+	tests := []struct {
+		name              string
+		solid             Solid
+		expectedIntervals [][2]float64
+	}{
+		{"box", &Box{Min: r3.Vec{X: -1, Y: -1, Z: -1}, Max: r3.Vec{X: 2, Y: 1, Z: 1}}, [][2]float64{{9, 12}}},
+		{"capped cylinder", &Cylinder{BaseCenter: r3.Vec{X: -2}, TopCenter: r3.Vec{X: 1}, Radius: 1, Capped: true}, [][2]float64{{8, 11}}},
+		{"cylinder across", &Cylinder{BaseCenter: r3.Vec{Y: -1}, TopCenter: r3.Vec{Y: 1}, Radius: 2, Capped: true}, [][2]float64{{8, 12}}},
+		{"torus", &Torus{Center: r3.Vec{}, Axis: r3.Vec{Y: 1}, MajorRadius: 3, MinorRadius: 1}, [][2]float64{{6, 8}, {12, 14}}},
+	}
+	for _, test := range tests {
+		intervals := test.solid.intervals(&alongX)
+		if len(intervals) != len(test.expectedIntervals) {
+			t.Fatalf("%s: expected %d intervals, got %d", test.name, len(test.expectedIntervals), len(intervals))
+		}
+		for i, interval := range intervals {
+			if math.Abs(interval.enter.t-test.expectedIntervals[i][0]) > 1e-6 || math.Abs(interval.exit.t-test.expectedIntervals[i][1]) > 1e-6 {
+				t.Errorf("%s: expected interval %v, got [%f, %f]", test.name, test.expectedIntervals[i], interval.enter.t, interval.exit.t)
+			}
+			// normals face out of the solid, against the ray when entering and along it when exiting
+			if r3.Dot(interval.enter.normal, alongX.normalizedDirection) >= 0 || r3.Dot(interval.exit.normal, alongX.normalizedDirection) <= 0 {
+				t.Errorf("%s: expected outward normals, got %v entering and %v exiting", test.name, interval.enter.normal, interval.exit.normal)
+			}
+		}
+	}
+}
+
+func TestCSGOperandsMustBeClosed(t *testing.T) {
+	sphere := &Sphere{Radius: 1}
+	for name, operand := range map[string]Solid{
+		"uncapped cylinder": &Cylinder{BaseCenter: r3.Vec{Y: -1}, TopCenter: r3.Vec{Y: 1}, Radius: 1},
+		"uncapped cone":     &Cone{BaseCenter: r3.Vec{Y: -1}, Apex: r3.Vec{Y: 1}, Radius: 1},
+		"nested":            &CSG{Operation: Union, Left: sphere, Right: &Cylinder{TopCenter: r3.Vec{Y: 1}, Radius: 1}},
+		"transformed disk":  NewTransformedShape(&Disk{Normal: r3.Vec{Y: 1}, Radius: 1}, IdentityMatrix()),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected NewCSG to panic on the %s operand", name)
+				}
+			}()
+			NewCSG(Difference, sphere, operand)
+		}()
+
+		// nor are they rendered when read from a scene file
+		_, scene := benchmarkScene()
+		scene.Shapes = append(scene.Shapes, NewTransformedShape(&CSG{Operation: Union, Left: operand, Right: sphere}, TranslationMatrix(r3.Vec{Y: 1})))
+		if err := scene.validate(); err == nil {
+			t.Errorf("expected an error validating a scene with a CSG with the %s operand", name)
+		}
+	}
+	NewCSG(Difference, sphere, &Cylinder{TopCenter: r3.Vec{Y: 1}, Radius: 1, Capped: true})
+}
@@ -0,0 +1,179 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"sync"
+)
+
+const (
+	defaultDenoiseRadius       = 4
+	defaultDenoiseSpatialSigma = 2.0
+	defaultDenoiseColorSigma   = 0.1
+	defaultDenoiseAlbedoSigma  = 0.1
+	defaultDenoiseNormalSigma  = 0.5
+	defaultDenoiseDepthSigma   = 0.2
+)
+
+// cross bilateral filter, averages each pixel with its neighbours when the AOVs say they show the same surface
+// zero values use the defaults, the AOVs used are the "albedo", "normal" and "depth" layers that were rendered
+type DenoiseSpec struct {
+	Radius       int     // of the square of neighbours averaged, in pixels
+	SpatialSigma float64 // in pixels
+	ColorSigma   float64 // difference between the colors around the pixels, large values let the AOVs decide alone
+	AlbedoSigma  float64
+	NormalSigma  float64 // 1 minus the cosine of the angle between the normals
+	DepthSigma   float64 // difference in depth relative to the depth of the pixel
+	WorkerCount  int     // rows are split between the workers, defaults to 1
+}
+
+func (ds DenoiseSpec) withDefaults() DenoiseSpec {
+	if ds.Radius <= 0 {
+		ds.Radius = defaultDenoiseRadius
+	}
+	if ds.SpatialSigma <= 0 {
+		ds.SpatialSigma = defaultDenoiseSpatialSigma
+	}
+	if ds.ColorSigma <= 0 {
+		ds.ColorSigma = defaultDenoiseColorSigma
+	}
+	if ds.AlbedoSigma <= 0 {
+		ds.AlbedoSigma = defaultDenoiseAlbedoSigma
+	}
+	if ds.NormalSigma <= 0 {
+		ds.NormalSigma = defaultDenoiseNormalSigma
+	}
+	if ds.DepthSigma <= 0 {
+		ds.DepthSigma = defaultDenoiseDepthSigma
+	}
+	if ds.WorkerCount <= 0 {
+		ds.WorkerCount = 1
+	}
+	return ds
+}
+
+// returns a denoised copy of the framebuffer, aovs can be nil to filter on the colors alone
+// shadows and reflections have no edges in the AOVs, so the colors keep them from being blurred
+// the AOVs have to be rendered at the size of the framebuffer, it panics when a layer used is another size
+func Denoise(fb *Framebuffer, aovs *AOVImages, spec DenoiseSpec) *Framebuffer {
+	spec = spec.withDefaults()
+	var albedo, normal, depth *Framebuffer
+	if aovs != nil {
+		albedo, normal, depth = aovs.Layers["albedo"], aovs.Layers["normal"], aovs.Layers["depth"]
+	}
+	for name, layer := range map[string]*Framebuffer{"albedo": albedo, "normal": normal, "depth": depth} {
+		if layer != nil && (layer.Width != fb.Width || layer.Height != fb.Height) {
+			panic(fmt.Sprintf("Expected the %s AOV to be %dx%d like the image, got %dx%d", name, fb.Width, fb.Height, layer.Width, layer.Height))
+		}
+	}
+	denoised := NewFramebuffer(fb.Width, fb.Height)
+	copy(denoised.Samples, fb.Samples)
+
+	// colors are compared by the mean of the pixels around them, which is less noisy than the pixels alone
+	guide := boxFilter(fb, 1)
+	// anti-aliased normals are averaged over the pixel, so they are shorter than 1 at edges and on curved surfaces
+	if normal != nil {
+		normal = unitNormals(normal)
+	}
+
+	// weights of the offsets from the pixel, the same for every pixel
+	spatial := make([]float64, (2*spec.Radius+1)*(2*spec.Radius+1))
+	for dy := -spec.Radius; dy <= spec.Radius; dy++ {
+		for dx := -spec.Radius; dx <= spec.Radius; dx++ {
+			spatial[(dy+spec.Radius)*(2*spec.Radius+1)+dx+spec.Radius] = math.Exp(-float64(dx*dx+dy*dy) / (2 * spec.SpatialSigma * spec.SpatialSigma))
+		}
+	}
+
+	rows := make(chan int, fb.Height)
+	for y := 0; y < fb.Height; y++ {
+		rows <- y
+	}
+	close(rows)
+	var wg sync.WaitGroup
+	for w := 0; w < spec.WorkerCount; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				for x := 0; x < fb.Width; x++ {
+					i := y*fb.Width + x
+					sum := r3.Vec{}
+					totalWeight := 0.0
+					for ny := y - spec.Radius; ny <= y+spec.Radius; ny++ {
+						if ny < 0 || ny >= fb.Height {
+							continue
+						}
+						for nx := x - spec.Radius; nx <= x+spec.Radius; nx++ {
+							if nx < 0 || nx >= fb.Width {
+								continue
+							}
+							j := ny*fb.Width + nx
+							weight := spatial[(ny-y+spec.Radius)*(2*spec.Radius+1)+nx-x+spec.Radius]
+							weight *= gaussianWeight(squaredDistance(guide.Pixels[i], guide.Pixels[j]), spec.ColorSigma)
+							if albedo != nil {
+								weight *= gaussianWeight(squaredDistance(albedo.Pixels[i], albedo.Pixels[j]), spec.AlbedoSigma)
+							}
+							// the normals of pixels that hit nothing are zero, they are told apart by color and depth alone
+							if normal != nil && normal.Pixels[i] != (r3.Vec{}) && normal.Pixels[j] != (r3.Vec{}) {
+								d := 1 - r3.Dot(normal.Pixels[i], normal.Pixels[j])
+								weight *= gaussianWeight(d*d, spec.NormalSigma)
+							}
+							if depth != nil {
+								d := (depth.Pixels[i].X - depth.Pixels[j].X) / math.Max(depth.Pixels[i].X, 1e-9)
+								weight *= gaussianWeight(d*d, spec.DepthSigma)
+							}
+							sum = r3.Add(sum, r3.Scale(weight, fb.Pixels[j]))
+							totalWeight += weight
+						}
+					}
+					// the pixel itself always has a weight of 1, every term compares it to itself
+					denoised.Pixels[i] = r3.Scale(1/totalWeight, sum)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return denoised
+}
+
+// copy of the normal AOV with every normal scaled to a length of 1, normals of 0 stay 0
+func unitNormals(normal *Framebuffer) *Framebuffer {
+	unit := NewFramebuffer(normal.Width, normal.Height)
+	for i, n := range normal.Pixels {
+		if n != (r3.Vec{}) {
+			unit.Pixels[i] = r3.Unit(n)
+		}
+	}
+	return unit
+}
+
+func gaussianWeight(squaredDistance float64, sigma float64) float64 {
+	return math.Exp(-squaredDistance / (2 * sigma * sigma))
+}
+
+func squaredDistance(a r3.Vec, b r3.Vec) float64 {
+	d := r3.Sub(a, b)
+	return r3.Dot(d, d)
+}
+
+// mean of the square of pixels around each pixel, cut off at the edges of the image
+func boxFilter(fb *Framebuffer, radius int) *Framebuffer {
+	filtered := NewFramebuffer(fb.Width, fb.Height)
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			sum := r3.Vec{}
+			n := 0
+			for ny := y - radius; ny <= y+radius; ny++ {
+				for nx := x - radius; nx <= x+radius; nx++ {
+					if nx >= 0 && nx < fb.Width && ny >= 0 && ny < fb.Height {
+						sum = r3.Add(sum, fb.At(nx, ny))
+						n++
+					}
+				}
+			}
+			filtered.Set(x, y, r3.Scale(1/float64(n), sum))
+		}
+	}
+	return filtered
+}
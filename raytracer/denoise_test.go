@@ -0,0 +1,76 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"testing"
+)
+
+func TestDenoise(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 80, 60
+	scene.Shapes[1] = &Sphere{Center: r3.Vec{X: -1.5}, Radius: 1, Mat: Metal{Albedo: r3.Vec{X: 0.8, Y: 0.8, Z: 0.8}, Fuzz: 0.5}}
+	var noisy, reference *Framebuffer
+	var aovs *AOVImages
+	withoutStdout(t, func() {
+		noisy = RenderFramebuffer(imageSpec, scene)
+		aovs = RenderAOVs(imageSpec, scene, AOVSpec{AOVs: []AOV{AlbedoAOV, NormalAOV, DepthAOV}})
+		referenceSpec := imageSpec
+		referenceSpec.AntiAliasingFactor = 64
+		reference = RenderFramebuffer(referenceSpec, scene)
+	})
+
+	meanSquaredError := func(fb *Framebuffer) float64 {
+		total := 0.0
+		for i := range fb.Pixels {
+			total += squaredDistance(fb.Pixels[i], reference.Pixels[i])
+		}
+		return total / float64(len(fb.Pixels))
+	}
+	noisyError := meanSquaredError(noisy)
+	guidedError := meanSquaredError(Denoise(noisy, aovs, DenoiseSpec{WorkerCount: 4}))
+	if guidedError >= 0.8*noisyError {
+		t.Errorf("expected denoising to lower the error of %f by a fifth, got %f", noisyError, guidedError)
+	}
+
+	// a flat color stays the same
+	flat := NewFramebuffer(10, 10)
+	for i := range flat.Pixels {
+		flat.Pixels[i] = r3.Vec{X: 0.25, Y: 0.5, Z: 0.75}
+	}
+	for i, c := range Denoise(flat, nil, DenoiseSpec{}).Pixels {
+		if squaredDistance(c, flat.Pixels[i]) > 1e-18 {
+			t.Fatalf("expected a flat image to stay the same, got %v", c)
+		}
+	}
+
+	// two faces of the same color meeting at an edge, lit differently, with anti-aliased normals shorter than 1 on the
+	// darker face, stay apart on the normals alone
+	edge := NewFramebuffer(16, 8)
+	edgeAOVs := &AOVImages{Width: 16, Height: 8, Layers: map[string]*Framebuffer{"albedo": NewFramebuffer(16, 8), "normal": NewFramebuffer(16, 8)}}
+	for y := 0; y < edge.Height; y++ {
+		for x := 0; x < edge.Width; x++ {
+			edgeAOVs.Layers["albedo"].Set(x, y, r3.Vec{X: 0.5, Y: 0.5, Z: 0.5})
+			if x < edge.Width/2 {
+				edge.Set(x, y, r3.Vec{X: 0.2, Y: 0.2, Z: 0.2})
+				edgeAOVs.Layers["normal"].Set(x, y, r3.Vec{Z: -0.2})
+			} else {
+				edge.Set(x, y, r3.Vec{X: 0.8, Y: 0.8, Z: 0.8})
+				edgeAOVs.Layers["normal"].Set(x, y, r3.Vec{X: 1})
+			}
+		}
+	}
+	sharp := Denoise(edge, edgeAOVs, DenoiseSpec{ColorSigma: 100, NormalSigma: 0.2})
+	for x := edge.Width/2 - 1; x <= edge.Width/2; x++ {
+		if c, expected := sharp.At(x, 4), edge.At(x, 4); squaredDistance(c, expected) > 1e-6 {
+			t.Errorf("expected the edge to stay sharp with %v at x=%d, got %v", expected, x, c)
+		}
+	}
+
+	// AOVs of another size than the image can't guide it
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected denoising with AOVs of another size to panic")
+		}
+	}()
+	Denoise(flat, aovs, DenoiseSpec{})
+}
@@ -0,0 +1,290 @@
+package raytracer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDistributedTimeout = 10 * time.Minute
+const maxTileRequestBytes = 4 << 10 // a tile request is the corners of the tile
+
+// renders the tiles of an image on other processes, eg the machines of a render farm
+// each worker process serves a RenderWorker over http
+type DistributedSpec struct {
+	WorkerURLs     []string      // eg http://render1:8700
+	TilesPerWorker int           // sent to each worker at the same time, eg its number of cores, defaults to 1
+	Timeout        time.Duration // for a worker to render a tile before it is given to another worker, defaults to 10 minutes
+}
+
+type tileRequest struct {
+	X0, Y0, X1, Y1 int
+}
+
+// renders an image across the workers, the image is the same as GenerateImage renders
+// a worker that fails or times out gets no more tiles, its tile is given to the others
+func GenerateImageDistributed(imageSpec ImageSpec, scene Scene, spec DistributedSpec) (*image.RGBA, error) {
+	job, err := encodeGob(renderJob{ImageSpec: imageSpec, Scene: scene})
+	if err != nil {
+		return nil, err
+	}
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = defaultDistributedTimeout
+	}
+	tilesPerWorker := spec.TilesPerWorker
+	if tilesPerWorker <= 0 {
+		tilesPerWorker = 1
+	}
+	c := coordinator{
+		client: &http.Client{Timeout: timeout},
+		jobID:  fmt.Sprintf("%d", time.Now().UnixNano()),
+		job:    job,
+	}
+
+	tiles := imageSpec.tiles()
+	pending := make(chan tile, len(tiles))
+	for _, t := range tiles {
+		pending <- t
+	}
+	type finishedTile struct {
+		tile   tile
+		result tileResult
+	}
+	finished := make(chan finishedTile)
+	alive := sync.WaitGroup{}
+	for _, url := range spec.WorkerURLs {
+		alive.Add(tilesPerWorker)
+		go func(url string) {
+			if err := c.sendJob(url); err != nil {
+				fmt.Printf("Worker %s failed to take the scene: %v\n", url, err)
+				alive.Add(-tilesPerWorker)
+				return
+			}
+			for slot := 0; slot < tilesPerWorker; slot++ {
+				go func() {
+					defer alive.Done()
+					for t := range pending {
+						result, err := c.renderTile(url, t)
+						if err != nil {
+							fmt.Printf("Worker %s failed, giving tile (%d, %d) to another worker: %v\n", url, t.x0, t.y0, err)
+							pending <- t
+							return
+						}
+						finished <- finishedTile{tile: t, result: result}
+					}
+				}()
+			}
+		}(url)
+	}
+	allFailed := make(chan struct{})
+	go func() {
+		alive.Wait()
+		close(allFailed)
+	}()
+	defer func() {
+		// without waiting, a worker that stopped responding would hold up the render until it timed out
+		for _, url := range spec.WorkerURLs {
+			go c.deleteJob(url)
+		}
+	}()
+
+	fb := NewFramebuffer(imageSpec.Width, imageSpec.Height)
+	startTime := time.Now()
+	for remaining := len(tiles); remaining > 0; remaining-- {
+		select {
+		case f := <-finished:
+			fb.addTile(f.tile, f.result)
+		case <-allFailed:
+			return nil, fmt.Errorf("all workers failed with %d of %d tiles left to render", remaining, len(tiles))
+		}
+	}
+	close(pending)
+	fmt.Printf("Finished distributed ray tracing in %s\n", time.Since(startTime).String())
+	return imageSpec.output(fb), nil
+}
+
+type coordinator struct {
+	client *http.Client
+	jobID  string
+	job    []byte // gob of the renderJob
+}
+
+func (c *coordinator) sendJob(url string) error {
+	req, err := http.NewRequest(http.MethodPut, url+"/jobs/"+c.jobID, bytes.NewReader(c.job))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return responseError(resp)
+}
+
+func (c *coordinator) renderTile(url string, t tile) (tileResult, error) {
+	request, err := encodeGob(tileRequest{X0: t.x0, Y0: t.y0, X1: t.x1, Y1: t.y1})
+	if err != nil {
+		return tileResult{}, err
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.client.Post(url+"/jobs/"+c.jobID+"/tiles", "application/octet-stream", bytes.NewReader(request))
+		if err != nil {
+			return tileResult{}, err
+		}
+		if resp.StatusCode == http.StatusNotFound && attempt == 0 {
+			// the worker was restarted and lost the scene
+			resp.Body.Close()
+			if err := c.sendJob(url); err != nil {
+				return tileResult{}, err
+			}
+			continue
+		}
+		result, err := readTileResult(resp, t)
+		resp.Body.Close()
+		return result, err
+	}
+}
+
+func (c *coordinator) deleteJob(url string) {
+	req, err := http.NewRequest(http.MethodDelete, url+"/jobs/"+c.jobID, nil)
+	if err != nil {
+		return
+	}
+	if resp, err := c.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func readTileResult(resp *http.Response, t tile) (tileResult, error) {
+	if err := responseError(resp); err != nil {
+		return tileResult{}, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return tileResult{}, err
+	}
+	result := tileResult{}
+	if err := decodeGob(data, &result); err != nil {
+		return tileResult{}, err
+	}
+	if len(result.Colors) != t.pixelCount() || len(result.Samples) != t.pixelCount() {
+		return tileResult{}, fmt.Errorf("expected %d pixels, got %d colors and %d samples", t.pixelCount(), len(result.Colors), len(result.Samples))
+	}
+	return result, nil
+}
+
+func responseError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+}
+
+// renders tiles for GenerateImageDistributed, serve it with http.ListenAndServe
+// scenes are kept until the coordinator deletes them, so a worker can render for several coordinators at once
+type RenderWorker struct {
+	lock sync.Mutex
+	jobs map[string]*workerJob
+}
+
+type workerJob struct {
+	imageSpec ImageSpec
+	scene     Scene
+	camera    camera
+	bvh       *boundingVolumeHierarchy
+	exposure  float64
+	fb        *Framebuffer // no samples, every tile starts from the first sample of its pixels
+}
+
+func NewRenderWorker() *RenderWorker {
+	return &RenderWorker{jobs: make(map[string]*workerJob)}
+}
+
+// PUT /jobs/{id} takes a job, POST /jobs/{id}/tiles renders a tile of it and DELETE /jobs/{id} drops it
+func (w *RenderWorker) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "jobs" && req.Method == http.MethodPut:
+		job, err := w.readJob(http.MaxBytesReader(rw, req.Body, maxRenderJobBytes))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.lock.Lock()
+		w.jobs[parts[1]] = job
+		w.lock.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[0] == "jobs" && req.Method == http.MethodDelete:
+		w.lock.Lock()
+		delete(w.jobs, parts[1])
+		w.lock.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "tiles" && req.Method == http.MethodPost:
+		w.lock.Lock()
+		job, ok := w.jobs[parts[1]]
+		w.lock.Unlock()
+		if !ok {
+			http.Error(rw, "no job "+parts[1], http.StatusNotFound)
+			return
+		}
+		result, err := job.renderTile(http.MaxBytesReader(rw, req.Body, maxTileRequestBytes))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/octet-stream")
+		rw.Write(result)
+	default:
+		http.NotFound(rw, req)
+	}
+}
+
+func (w *RenderWorker) readJob(body io.Reader) (*workerJob, error) {
+	job, err := loadRenderJob(body)
+	if err != nil {
+		return nil, err
+	}
+	// the same limits as the render service, the worker allocates the framebuffer up front
+	if err := job.validate(); err != nil {
+		return nil, err
+	}
+	shapes := job.Scene.renderShapes()
+	cam := job.Scene.camera(float64(job.ImageSpec.Width) / float64(job.ImageSpec.Height))
+	cam.setShutter(job.Scene.shutter())
+	return &workerJob{
+		imageSpec: job.ImageSpec,
+		scene:     job.Scene,
+		camera:    cam,
+		bvh:       NewBoundingVolumeHierarchy(&shapes),
+		exposure:  job.Scene.exposure(),
+		fb:        NewFramebuffer(job.ImageSpec.Width, job.ImageSpec.Height),
+	}, nil
+}
+
+func (j *workerJob) renderTile(body io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	request := tileRequest{}
+	if err := decodeGob(data, &request); err != nil {
+		return nil, err
+	}
+	t := tile{x0: request.X0, y0: request.Y0, x1: request.X1, y1: request.Y1}
+	if t.x0 < 0 || t.y0 < 0 || t.x1 > j.imageSpec.Width || t.y1 > j.imageSpec.Height || t.x0 >= t.x1 || t.y0 >= t.y1 {
+		return nil, errors.New("tile is outside of the image")
+	}
+	traceFunction := j.bvh.getTraceFunction(j.imageSpec.BvhTraversalAlgorithm)
+	sampler := newSampler(j.imageSpec.Sampler, j.imageSpec.AntiAliasingFactor)
+	return encodeGob(traceTile(&j.imageSpec, j.camera, j.bvh, traceFunction, &j.scene.Lights, j.exposure, sampler, j.fb, t))
+}
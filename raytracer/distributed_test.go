@@ -0,0 +1,140 @@
+package raytracer
+
+import (
+	"bytes"
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSceneRoundTrip(t *testing.T) {
+	_, scene := benchmarkScene()
+	moved := NewTransformedShape(&Sphere{Center: r3.Vec{Y: 2}, Radius: 0.5, Mat: Metal{Albedo: r3.Vec{X: 1, Y: 1, Z: 1}}}, TranslationMatrix(r3.Vec{X: 1}))
+	var object *Object
+	withoutStdout(t, func() {
+		object = NewObject([]Shape{&Sphere{Radius: 0.3, Mat: Standard{ColorFrac: r3.Vec{Y: 1}}}})
+	})
+	scene.Shapes = append(scene.Shapes, moved, NewInstance(object, TranslationMatrix(r3.Vec{X: -2, Y: 2})))
+	scene.CameraAperatureShape = PolygonalAperature{Blades: 5}
+
+	var buf bytes.Buffer
+	if err := WriteScene(&buf, &scene); err != nil {
+		t.Fatal(err)
+	}
+	var loaded *Scene
+	var err error
+	withoutStdout(t, func() {
+		// reading the instance builds its object again
+		loaded, err = LoadScene(&buf)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imageSpec, _ := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	var expected, img *image.RGBA
+	withoutStdout(t, func() {
+		expected = GenerateImage(imageSpec, scene)
+		img = GenerateImage(imageSpec, *loaded)
+	})
+	if !bytes.Equal(img.Pix, expected.Pix) {
+		t.Errorf("expected the loaded scene to render the same image")
+	}
+
+	scene.Shapes = append(scene.Shapes, &SDFShape{Distance: SDFSphere(r3.Vec{}, 1)})
+	if err := WriteScene(&buf, &scene); err == nil {
+		t.Errorf("expected an error writing an SDF shape")
+	}
+}
+
+// fails every tile after the first few, like a machine that went down part way through
+type failingWorker struct {
+	worker *RenderWorker
+	lock   sync.Mutex
+	tiles  int
+}
+
+func (f *failingWorker) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		f.lock.Lock()
+		f.tiles++
+		tiles := f.tiles
+		f.lock.Unlock()
+		if tiles > 2 {
+			http.Error(rw, "out of memory", http.StatusInternalServerError)
+			return
+		}
+	}
+	f.worker.ServeHTTP(rw, req)
+}
+
+func TestGenerateImageDistributed(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	imageSpec.TileSize = 8
+
+	workers := []*httptest.Server{
+		httptest.NewServer(NewRenderWorker()),
+		httptest.NewServer(NewRenderWorker()),
+		httptest.NewServer(&failingWorker{worker: NewRenderWorker()}),
+	}
+	stopped := httptest.NewServer(NewRenderWorker())
+	stopped.Close()
+	spec := DistributedSpec{TilesPerWorker: 2}
+	for _, w := range workers {
+		defer w.Close()
+		spec.WorkerURLs = append(spec.WorkerURLs, w.URL)
+	}
+	spec.WorkerURLs = append(spec.WorkerURLs, stopped.URL)
+
+	var expected, img *image.RGBA
+	var err error
+	withoutStdout(t, func() {
+		expected = GenerateImage(imageSpec, scene)
+		img, err = GenerateImageDistributed(imageSpec, scene, spec)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img.Pix, expected.Pix) {
+		t.Errorf("expected the distributed render to be the same as the local one")
+	}
+
+	withoutStdout(t, func() {
+		_, err = GenerateImageDistributed(imageSpec, scene, DistributedSpec{WorkerURLs: []string{stopped.URL}})
+	})
+	if err == nil {
+		t.Errorf("expected an error when no worker can render")
+	}
+}
+
+func TestRenderWorkerRejectsBadJobs(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	worker := httptest.NewServer(NewRenderWorker())
+	defer worker.Close()
+
+	for _, bad := range []func(is *ImageSpec){
+		func(is *ImageSpec) { is.Width, is.Height = 0, 0 },
+		func(is *ImageSpec) { is.Width, is.Height = 1<<20, 1<<20 },
+		func(is *ImageSpec) { is.Sampler = 99 },
+	} {
+		badSpec := imageSpec
+		bad(&badSpec)
+		var body bytes.Buffer
+		if err := WriteRenderJob(&body, badSpec, &scene); err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(http.MethodPut, worker.URL+"/jobs/bad", &body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected %+v to be a bad request, got %s", badSpec, resp.Status)
+		}
+	}
+}
@@ -214,7 +214,7 @@ func ExampleRegression(width, height int, repoBaseDir string) (is ImageSpec, sc
 		CameraLookFrom:   cameraLookFrom,
 		CameraLookAt:     cameraLookAt,
 		CameraUp:         cameraUp,
-		CameraFocusPoint: cameraFocusPoint,
+		CameraFocusPoint: &cameraFocusPoint,
 		CameraAperature:  cameraAperature,
 		CameraFov:        cameraFovDegrees,
 		Shapes:           shapes,
@@ -0,0 +1,102 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// one channel of an OpenEXR image, layers are named with a prefix, eg "normal.X"
+type exrChannel struct {
+	name   string
+	values []float32 // rows from the top of the image down
+}
+
+// writes the linear colors of the framebuffer as an OpenEXR image, keeping colors brighter than 1
+func WriteEXR(file io.Writer, fb *Framebuffer) error {
+	return writeEXR(file, fb.Width, fb.Height, fb.exrChannels(""))
+}
+
+// R, G and B channels of the framebuffer, with the layer as a prefix when there is one
+func (fb *Framebuffer) exrChannels(layer string) []exrChannel {
+	prefix := ""
+	if layer != "" {
+		prefix = layer + "."
+	}
+	r := exrChannel{name: prefix + "R", values: make([]float32, len(fb.Pixels))}
+	g := exrChannel{name: prefix + "G", values: make([]float32, len(fb.Pixels))}
+	b := exrChannel{name: prefix + "B", values: make([]float32, len(fb.Pixels))}
+	for i, c := range fb.Pixels {
+		r.values[i], g.values[i], b.values[i] = float32(c.X), float32(c.Y), float32(c.Z)
+	}
+	return []exrChannel{r, g, b}
+}
+
+// uncompressed scanline OpenEXR file with 32 bit float channels, see https://openexr.com/en/latest/OpenEXRFileLayout.html
+func writeEXR(file io.Writer, width int, height int, channels []exrChannel) error {
+	channels = append([]exrChannel(nil), channels...)
+	sort.Slice(channels, func(i, j int) bool { return channels[i].name < channels[j].name })
+
+	var header bytes.Buffer
+	le := binary.LittleEndian
+	header.Write([]byte{0x76, 0x2f, 0x31, 0x01})
+	binary.Write(&header, le, uint32(2))
+	attribute := func(name string, kind string, value []byte) {
+		header.WriteString(name + "\x00" + kind + "\x00")
+		binary.Write(&header, le, uint32(len(value)))
+		header.Write(value)
+	}
+	var chlist bytes.Buffer
+	for _, c := range channels {
+		chlist.WriteString(c.name + "\x00")
+		// float pixels, not perceptually linear, reserved bytes, no subsampling
+		binary.Write(&chlist, le, []int32{2, 0, 1, 1})
+	}
+	chlist.WriteByte(0)
+	window := make([]byte, 16)
+	le.PutUint32(window[8:], uint32(width-1))
+	le.PutUint32(window[12:], uint32(height-1))
+	float := func(f float32) []byte {
+		b := make([]byte, 4)
+		le.PutUint32(b, math.Float32bits(f))
+		return b
+	}
+	attribute("channels", "chlist", chlist.Bytes())
+	attribute("compression", "compression", []byte{0})
+	attribute("dataWindow", "box2i", window)
+	attribute("displayWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", []byte{0})
+	attribute("pixelAspectRatio", "float", float(1))
+	attribute("screenWindowCenter", "v2f", make([]byte, 8))
+	attribute("screenWindowWidth", "float", float(1))
+	header.WriteByte(0)
+
+	// every scanline is a chunk, after the table of where each chunk starts
+	lineSize := 4 * width * len(channels)
+	offset := uint64(header.Len() + 8*height)
+	for y := 0; y < height; y++ {
+		binary.Write(&header, le, offset+uint64(y*(8+lineSize)))
+	}
+	if _, err := file.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	line := make([]byte, 8+lineSize)
+	for y := 0; y < height; y++ {
+		le.PutUint32(line[0:], uint32(y))
+		le.PutUint32(line[4:], uint32(lineSize))
+		i := 8
+		for _, c := range channels {
+			for x := 0; x < width; x++ {
+				le.PutUint32(line[i:], math.Float32bits(c.values[y*width+x]))
+				i += 4
+			}
+		}
+		if _, err := file.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
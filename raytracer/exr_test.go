@@ -0,0 +1,59 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriteEXR(t *testing.T) {
+	fb := NewFramebuffer(3, 2)
+	for i := range fb.Pixels {
+		fb.Pixels[i] = r3.Vec{X: float64(i), Y: 0.5, Z: 2.5}
+	}
+	var buf bytes.Buffer
+	if err := WriteEXR(&buf, fb); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	le := binary.LittleEndian
+	if le.Uint32(data) != 20000630 {
+		t.Fatalf("expected the OpenEXR magic number, got %x", data[:4])
+	}
+
+	// attributes up to the empty name that ends the header
+	attributes := map[string][]byte{}
+	i := 8
+	for data[i] != 0 {
+		name := string(data[i : i+bytes.IndexByte(data[i:], 0)])
+		i += len(name) + 1
+		i += bytes.IndexByte(data[i:], 0) + 1
+		size := int(le.Uint32(data[i:]))
+		attributes[name] = data[i+4 : i+4+size]
+		i += 4 + size
+	}
+	i++
+	for _, name := range []string{"channels", "compression", "dataWindow", "displayWindow", "lineOrder", "pixelAspectRatio", "screenWindowCenter", "screenWindowWidth"} {
+		if _, ok := attributes[name]; !ok {
+			t.Errorf("expected a %s attribute", name)
+		}
+	}
+	if channels := strings.Split(string(attributes["channels"]), "\x00"); channels[0] != "B" {
+		t.Errorf("expected the channels in alphabetical order, got %q", channels[0])
+	}
+
+	// B, G and R values of the second scanline
+	offset := int(le.Uint64(data[i+8:]))
+	if y := le.Uint32(data[offset:]); y != 1 {
+		t.Fatalf("expected the chunk of scanline 1, got %d", y)
+	}
+	value := func(channel, x int) float32 {
+		return math.Float32frombits(le.Uint32(data[offset+8+(channel*3+x)*4:]))
+	}
+	if value(0, 1) != 2.5 || value(1, 1) != 0.5 || value(2, 1) != 4 {
+		t.Errorf("expected pixel (1, 1) to be (4, 0.5, 2.5), got (%f, %f, %f)", value(2, 1), value(1, 1), value(0, 1))
+	}
+}
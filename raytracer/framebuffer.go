@@ -0,0 +1,65 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"math"
+)
+
+// linear colors of a rendered image before they are clamped into 8 bits, rows go from the top of the image down
+type Framebuffer struct {
+	Width   int
+	Height  int
+	Pixels  []r3.Vec
+	Samples []int // taken for each pixel, differs between pixels with adaptive sampling
+}
+
+func NewFramebuffer(width int, height int) *Framebuffer {
+	return &Framebuffer{
+		Width:   width,
+		Height:  height,
+		Pixels:  make([]r3.Vec, width*height),
+		Samples: make([]int, width*height),
+	}
+}
+
+func (fb *Framebuffer) At(x int, y int) r3.Vec {
+	return fb.Pixels[y*fb.Width+x]
+}
+
+func (fb *Framebuffer) Set(x int, y int, c r3.Vec) {
+	fb.Pixels[y*fb.Width+x] = c
+}
+
+// adds n samples with a mean color of c to a pixel
+func (fb *Framebuffer) addSamples(x int, y int, c r3.Vec, n int) {
+	i := y*fb.Width + x
+	if fb.Samples[i] > 0 {
+		total := float64(fb.Samples[i] + n)
+		c = r3.Add(r3.Scale(float64(fb.Samples[i])/total, fb.Pixels[i]), r3.Scale(float64(n)/total, c))
+	}
+	fb.Pixels[i] = c
+	fb.Samples[i] += n
+}
+
+func (fb *Framebuffer) addTile(t tile, result tileResult) {
+	i := 0
+	for y := t.y0; y < t.y1; y++ {
+		for x := t.x0; x < t.x1; x++ {
+			fb.addSamples(x, y, result.Colors[i], result.Samples[i])
+			i++
+		}
+	}
+}
+
+// colors brighter than 1 are clamped
+func (fb *Framebuffer) ToRGBA() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, fb.Width, fb.Height))
+	for i, c := range fb.Pixels {
+		img.Pix[i*4+0] = uint8(math.Min(255, c.X*255.99))
+		img.Pix[i*4+1] = uint8(math.Min(255, c.Y*255.99))
+		img.Pix[i*4+2] = uint8(math.Min(255, c.Z*255.99))
+		img.Pix[i*4+3] = 255
+	}
+	return img
+}
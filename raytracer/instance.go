@@ -0,0 +1,104 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+)
+
+// group of shapes with their own bounding volume hierarchy, which can be placed in a scene many times by Instances
+type Object struct {
+	shapes []Shape
+	bvh    *boundingVolumeHierarchy
+	pMin   r3.Vec
+	pMax   r3.Vec
+}
+
+// an Object placed in the scene by a transform, many Instances can share the same Object
+type Instance struct {
+	Object    *Object
+	transform Matrix4
+	inverse   Matrix4
+}
+
+func NewObject(shapes []Shape) *Object {
+	o := Object{
+		shapes: shapes,
+		pMin:   r3.Vec{X: math.MaxFloat64, Y: math.MaxFloat64, Z: math.MaxFloat64},
+		pMax:   r3.Vec{X: -math.MaxFloat64, Y: -math.MaxFloat64, Z: -math.MaxFloat64},
+	}
+	for _, s := range o.shapes {
+		lowest, highest := s.computeSquareBounds()
+		o.pMin.X = math.Min(o.pMin.X, lowest.X)
+		o.pMin.Y = math.Min(o.pMin.Y, lowest.Y)
+		o.pMin.Z = math.Min(o.pMin.Z, lowest.Z)
+		o.pMax.X = math.Max(o.pMax.X, highest.X)
+		o.pMax.Y = math.Max(o.pMax.Y, highest.Y)
+		o.pMax.Z = math.Max(o.pMax.Z, highest.Z)
+	}
+	o.bvh = NewBoundingVolumeHierarchy(&o.shapes)
+	return &o
+}
+
+func NewInstance(object *Object, transform Matrix4) *Instance {
+	return &Instance{
+		Object:    object,
+		transform: transform,
+		inverse:   transform.Inverse(),
+	}
+}
+
+func (in *Instance) Transform() Matrix4 {
+	return in.transform
+}
+
+func (in *Instance) SetTransform(transform Matrix4) {
+	in.transform = transform
+	in.inverse = transform.Inverse()
+}
+
+func (in Instance) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	objectRay, scale := transformRay(&in.inverse, r)
+	hit, hr := in.Object.bvh.trace(&objectRay, tMin*scale)
+	if !hit || hr.t > tMax*scale {
+		return hitRecord{t: -1}
+	}
+	return transformHitRecord(r, hr, scale, &in.transform, &in.inverse)
+}
+
+func (in *Instance) Translate(tv r3.Vec) {
+	in.SetTransform(TranslationMatrix(tv).Multiply(in.transform))
+}
+
+// scales around the centroid of the instance
+func (in *Instance) Scale(c float64) {
+	in.SetTransform(PivotMatrix(ScalingMatrix(r3.Vec{X: c, Y: c, Z: c}), in.centroid()).Multiply(in.transform))
+}
+
+// rotates around the world origin, unlike Scale
+func (in *Instance) Rotate(rv r3.Vec) {
+	in.SetTransform(RotationMatrix(rv).Multiply(in.transform))
+}
+
+func (in Instance) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	return transformBounds(&in.transform, in.Object.pMin, in.Object.pMax)
+}
+
+func (in Instance) centroid() r3.Vec {
+	return in.transform.TransformPoint(r3.Scale(0.5, r3.Add(in.Object.pMin, in.Object.pMax)))
+}
+
+func (in Instance) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	// texture lookups happen on the shape that was hit, see transformedSurface
+	return 0, 0
+}
+
+func (in Instance) description() string {
+	return fmt.Sprintf(
+		"%s - Shapes: %d, Transform: %v",
+		reflect.TypeOf(in),
+		len(in.Object.shapes),
+		in.transform,
+	)
+}
@@ -0,0 +1,39 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+// an instance of an object must be hit exactly where the equivalent transformed shape is hit
+func TestInstanceMatchesTransformedShape(t *testing.T) {
+	object := NewObject([]Shape{
+		&Sphere{Center: r3.Vec{}, Radius: 1, Mat: Standard{}},
+	})
+	instance := NewInstance(object, IdentityMatrix())
+	instance.Scale(2)
+	instance.Translate(r3.Vec{X: 5, Y: 0, Z: 0})
+	expected := Sphere{Center: r3.Vec{X: 5, Y: 0, Z: 0}, Radius: 2, Mat: Standard{}}
+
+	rays := []ray{
+		{p: r3.Vec{X: 5, Y: 0, Z: -10}, normalizedDirection: r3.Vec{X: 0, Y: 0, Z: 1}},
+		{p: r3.Vec{X: 6, Y: 1, Z: -10}, normalizedDirection: r3.Vec{X: 0, Y: 0, Z: 1}},
+		{p: r3.Vec{X: 0, Y: 0, Z: 0}, normalizedDirection: r3.Unit(r3.Vec{X: 5, Y: 0.5, Z: 0.5})},
+	}
+	for i, r := range rays {
+		got := instance.hit(&r, 0, math.MaxFloat64)
+		exp := expected.hit(&r, 0, math.MaxFloat64)
+		if math.Abs(got.t-exp.t) > 1e-9 {
+			t.Errorf("ray %d: instance hit at t=%f, expected t=%f", i, got.t, exp.t)
+		}
+		if r3.Norm(r3.Sub(got.normal, exp.normal)) > 1e-9 {
+			t.Errorf("ray %d: instance normal %v, expected %v", i, got.normal, exp.normal)
+		}
+	}
+
+	missed := ray{p: r3.Vec{X: 0, Y: 0, Z: -10}, normalizedDirection: r3.Vec{X: 0, Y: 0, Z: 1}}
+	if hr := instance.hit(&missed, 0, math.MaxFloat64); hr.t > 0 {
+		t.Errorf("expected ray to miss the instance, but hit at t=%f", hr.t)
+	}
+}
@@ -12,7 +12,7 @@ type Light interface {
 	getLightIntensity() float64
 	getSpecularLightIntensity() float64
 	getInverseSquareLawDecayFactor() float64
-	isPointVisible(point *r3.Vec, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool
+	isPointVisible(point *r3.Vec, incoming *ray, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool
 }
 
 type AmbientLight struct {
@@ -62,7 +62,7 @@ func (a AmbientLight) getInverseSquareLawDecayFactor() float64 {
 	return 0
 }
 
-func (a AmbientLight) isPointVisible(point *r3.Vec, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool {
+func (a AmbientLight) isPointVisible(point *r3.Vec, incoming *ray, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool {
 	return true
 }
 
@@ -90,9 +90,9 @@ func (p PointLight) getInverseSquareLawDecayFactor() float64 {
 	return p.InverseSquareLawDecayFactor
 }
 
-func (p PointLight) isPointVisible(point *r3.Vec, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool {
+func (p PointLight) isPointVisible(point *r3.Vec, incoming *ray, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool {
 	shiftedPosition := r3.Add(p.Position, *monteCarloVariance)
-	return doesReachLight(point, &shiftedPosition, traceFunction)
+	return doesReachLight(point, &shiftedPosition, incoming, traceFunction)
 }
 
 func (s SpotLight) hasPosition() bool {
@@ -119,9 +119,9 @@ func (s SpotLight) getInverseSquareLawDecayFactor() float64 {
 	return s.InverseSquareLawDecayFactor
 }
 
-func (s SpotLight) isPointVisible(point *r3.Vec, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool {
+func (s SpotLight) isPointVisible(point *r3.Vec, incoming *ray, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), monteCarloVariance *r3.Vec) bool {
 	shiftedPosition := r3.Add(s.Position, *monteCarloVariance)
-	reachesLight := doesReachLight(point, &shiftedPosition, traceFunction)
+	reachesLight := doesReachLight(point, &shiftedPosition, incoming, traceFunction)
 
 	// get angle between light direction vector and vector of light to point
 	lightDirection := r3.Unit(r3.Sub(s.LookAt, s.Position))
@@ -138,12 +138,15 @@ func angleBetweenVectors(a, b *r3.Vec) float64 {
 	return angleRadians * 180 / math.Pi
 }
 
-func doesReachLight(origin *r3.Vec, lightPosition *r3.Vec, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord)) bool {
+func doesReachLight(origin *r3.Vec, lightPosition *r3.Vec, incoming *ray, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord)) bool {
 	lightDirection := r3.Sub(*lightPosition, *origin)
 	unitLightDirection := r3.Unit(lightDirection)
+	// shadows of moving shapes are cast from where they are at the same time
 	r := ray{
 		p:                   *origin,
 		normalizedDirection: unitLightDirection,
+		time:                incoming.time,
+		samples:             incoming.samples,
 	}
 	hit, hitRecord := traceFunction(
 		&r,
@@ -3,11 +3,12 @@ package raytracer
 import (
 	"gonum.org/v1/gonum/spatial/r3"
 	"math"
-	"math/rand"
 )
 
 type Material interface {
 	scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), lights *[]Light) (shouldTrace bool, attenuation r3.Vec, scattered ray, color r3.Vec)
+	// color of the surface without any lighting, eg for the albedo AOV
+	albedoColor(hitRecord *hitRecord) r3.Vec
 }
 
 type Standard struct {
@@ -46,7 +47,7 @@ func (m Metal) scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFunctio
 		correctedFuzz = m.Fuzz
 	}
 	reflectedRay := reflected(&r.normalizedDirection, &hitRecord.normal)
-	return r3.Dot(reflectedRay, hitRecord.normal) > 0, m.Albedo, ray{p: hitRecord.p, normalizedDirection: r3.Add(reflectedRay, r3.Scale(correctedFuzz, randomInUnitSphere()))}, r3.Vec{}
+	return r3.Dot(reflectedRay, hitRecord.normal) > 0, m.Albedo, ray{p: hitRecord.p, normalizedDirection: r3.Add(reflectedRay, r3.Scale(correctedFuzz, randomInUnitSphere(r.samples))), time: r.time, samples: r.samples}, r3.Vec{}
 }
 
 func (d Dielectric) scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), lights *[]Light) (shouldTrace bool, attenuation r3.Vec, scattered ray, color r3.Vec) {
@@ -61,12 +62,12 @@ func (d Dielectric) scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFu
 	sinTheta := math.Sqrt(1 - cosTheta*cosTheta)
 	cannotRefract := refractionRatio*sinTheta > 1.0
 	direction := r3.Vec{}
-	if cannotRefract || schlick(cosTheta, refractionRatio) > rand.Float64() {
+	if cannotRefract || schlick(cosTheta, refractionRatio) > r.samples.random() {
 		direction = reflected(&r.normalizedDirection, &hitRecord.normal)
 	} else {
 		direction = refracted(&r.normalizedDirection, &hitRecord.normal, refractionRatio)
 	}
-	return true, r3.Vec{X: 1.0, Y: 1.0, Z: 1.0}, ray{p: r3.Add(hitRecord.p, r3.Scale(0.00001, direction)), normalizedDirection: direction}, r3.Vec{}
+	return true, r3.Vec{X: 1.0, Y: 1.0, Z: 1.0}, ray{p: r3.Add(hitRecord.p, r3.Scale(0.00001, direction)), normalizedDirection: direction, time: r.time, samples: r.samples}, r3.Vec{}
 }
 
 // see https://www.cs.uregina.ca/Links/class-info/315/WWW/Lab4/#Lighting
@@ -78,8 +79,8 @@ func (p PhongBlinn) scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFu
 			monteCarloMaxLength := softShadowMonteCarloMaxLengthDeviation
 			for i := 0; i < monteCarloRepetitions; i++ {
 				hitPoint := hitRecord.p
-				monteCarloVariance := r3.Scale(monteCarloMaxLength, randomInUnitSphere())
-				if light.isPointVisible(&hitPoint, traceFunction, &monteCarloVariance) {
+				monteCarloVariance := r3.Scale(monteCarloMaxLength, r.samples.inUnitSphere())
+				if light.isPointVisible(&hitPoint, r, traceFunction, &monteCarloVariance) {
 					lightPosition := *light.getPosition()
 					lightToPoint := r3.Sub(lightPosition, hitPoint)
 					lightDirection := r3.Unit(lightToPoint)
@@ -131,10 +132,33 @@ func (p PhongBlinn) scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFu
 	return false, r3.Vec{}, ray{}, c
 }
 
-func randomInUnitSphere() r3.Vec {
+func (d Standard) albedoColor(hitRecord *hitRecord) r3.Vec {
+	if d.Texture != nil {
+		return d.Texture.getColorFrac(hitRecord.shape.textureMap(hitRecord.p, hitRecord.normal))
+	}
+	return d.ColorFrac
+}
+
+func (m Metal) albedoColor(hitRecord *hitRecord) r3.Vec {
+	return m.Albedo
+}
+
+// clear glass lets all of the light through
+func (d Dielectric) albedoColor(hitRecord *hitRecord) r3.Vec {
+	return r3.Vec{X: 1, Y: 1, Z: 1}
+}
+
+func (p PhongBlinn) albedoColor(hitRecord *hitRecord) r3.Vec {
+	if p.Texture != nil {
+		return p.Texture.getColorFrac(hitRecord.shape.textureMap(hitRecord.p, hitRecord.normal))
+	}
+	return p.ColorFrac
+}
+
+func randomInUnitSphere(samples *pixelSamples) r3.Vec {
 	p := r3.Vec{}
 	for {
-		p = r3.Sub(r3.Scale(2, r3.Vec{X: rand.Float64(), Y: rand.Float64(), Z: rand.Float64()}), r3.Vec{X: 1, Y: 1, Z: 1})
+		p = r3.Sub(r3.Scale(2, r3.Vec{X: samples.random(), Y: samples.random(), Z: samples.random()}), r3.Vec{X: 1, Y: 1, Z: 1})
 		if p.X*p.X+p.Y*p.Y+p.Z*p.Z < 1.0 {
 			break
 		}
@@ -0,0 +1,186 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+)
+
+// 4x4 affine transformation matrix, indexed as [row][column] and applied to column vectors
+type Matrix4 [4][4]float64
+
+func IdentityMatrix() Matrix4 {
+	return Matrix4{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+func TranslationMatrix(tv r3.Vec) Matrix4 {
+	m := IdentityMatrix()
+	m[0][3] = tv.X
+	m[1][3] = tv.Y
+	m[2][3] = tv.Z
+	return m
+}
+
+func ScalingMatrix(sv r3.Vec) Matrix4 {
+	m := IdentityMatrix()
+	m[0][0] = sv.X
+	m[1][1] = sv.Y
+	m[2][2] = sv.Z
+	return m
+}
+
+// rotation vector is in degrees, applied around the z, x and then y axis (same as rotatePoint)
+func RotationMatrix(rv r3.Vec) Matrix4 {
+	piDivide180 := math.Pi / 180.0
+	sinX, cosX := math.Sincos(piDivide180 * rv.X)
+	sinY, cosY := math.Sincos(piDivide180 * rv.Y)
+	sinZ, cosZ := math.Sincos(piDivide180 * rv.Z)
+	rz := Matrix4{
+		{cosZ, -sinZ, 0, 0},
+		{sinZ, cosZ, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+	rx := Matrix4{
+		{1, 0, 0, 0},
+		{0, cosX, -sinX, 0},
+		{0, sinX, cosX, 0},
+		{0, 0, 0, 1},
+	}
+	ry := Matrix4{
+		{cosY, 0, sinY, 0},
+		{0, 1, 0, 0},
+		{-sinY, 0, cosY, 0},
+		{0, 0, 0, 1},
+	}
+	return ry.Multiply(rx.Multiply(rz))
+}
+
+// returns m * o, meaning o is applied first and then m
+func (m Matrix4) Multiply(o Matrix4) Matrix4 {
+	res := Matrix4{}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				res[i][j] += m[i][k] * o[k][j]
+			}
+		}
+	}
+	return res
+}
+
+func (m Matrix4) Transpose() Matrix4 {
+	res := Matrix4{}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			res[i][j] = m[j][i]
+		}
+	}
+	return res
+}
+
+// inverts the matrix using gauss-jordan elimination, panics if the matrix is singular
+func (m Matrix4) Inverse() Matrix4 {
+	a := m
+	inv := IdentityMatrix()
+	for col := 0; col < 4; col++ {
+		// partial pivoting, pick the row with the largest value in this column
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			panic("Matrix4 is singular and cannot be inverted")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		scale := 1 / a[col][col]
+		for j := 0; j < 4; j++ {
+			a[col][j] *= scale
+			inv[col][j] *= scale
+		}
+		for row := 0; row < 4; row++ {
+			if row != col {
+				factor := a[row][col]
+				for j := 0; j < 4; j++ {
+					a[row][j] -= factor * a[col][j]
+					inv[row][j] -= factor * inv[col][j]
+				}
+			}
+		}
+	}
+	return inv
+}
+
+func (m Matrix4) TransformPoint(p r3.Vec) r3.Vec {
+	return r3.Vec{
+		X: m[0][0]*p.X + m[0][1]*p.Y + m[0][2]*p.Z + m[0][3],
+		Y: m[1][0]*p.X + m[1][1]*p.Y + m[1][2]*p.Z + m[1][3],
+		Z: m[2][0]*p.X + m[2][1]*p.Y + m[2][2]*p.Z + m[2][3],
+	}
+}
+
+// transforms a direction, ignoring translation
+func (m Matrix4) TransformVector(v r3.Vec) r3.Vec {
+	return r3.Vec{
+		X: m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		Y: m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		Z: m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// normals are transformed by the inverse transpose, so this takes the inverse of the transform being applied
+func transformNormal(inverse *Matrix4, n r3.Vec) r3.Vec {
+	return r3.Unit(r3.Vec{
+		X: inverse[0][0]*n.X + inverse[1][0]*n.Y + inverse[2][0]*n.Z,
+		Y: inverse[0][1]*n.X + inverse[1][1]*n.Y + inverse[2][1]*n.Z,
+		Z: inverse[0][2]*n.X + inverse[1][2]*n.Y + inverse[2][2]*n.Z,
+	})
+}
+
+// transforms a ray by m, the returned ray has a normalized direction so t values are scaled by the returned factor
+// ie tTransformed = tOriginal * scale
+func transformRay(m *Matrix4, r *ray) (transformed ray, scale float64) {
+	direction := m.TransformVector(r.normalizedDirection)
+	scale = math.Sqrt(r3.Dot(direction, direction))
+	transformed = *r
+	transformed.p = m.TransformPoint(r.p)
+	transformed.normalizedDirection = r3.Scale(1/scale, direction)
+	return transformed, scale
+}
+
+// transforms the 8 corners of the box and returns the box that bounds all of them
+func transformBounds(m *Matrix4, pMin r3.Vec, pMax r3.Vec) (lowest r3.Vec, highest r3.Vec) {
+	if isUnbounded(pMin, pMax) {
+		return r3.Vec{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}, r3.Vec{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+	}
+	lowest = r3.Vec{X: math.MaxFloat64, Y: math.MaxFloat64, Z: math.MaxFloat64}
+	highest = r3.Vec{X: -math.MaxFloat64, Y: -math.MaxFloat64, Z: -math.MaxFloat64}
+	for i := 0; i < 8; i++ {
+		corner := pMin
+		if i&1 != 0 {
+			corner.X = pMax.X
+		}
+		if i&2 != 0 {
+			corner.Y = pMax.Y
+		}
+		if i&4 != 0 {
+			corner.Z = pMax.Z
+		}
+		p := m.TransformPoint(corner)
+		lowest.X = math.Min(lowest.X, p.X)
+		lowest.Y = math.Min(lowest.Y, p.Y)
+		lowest.Z = math.Min(lowest.Z, p.Z)
+		highest.X = math.Max(highest.X, p.X)
+		highest.Y = math.Max(highest.Y, p.Y)
+		highest.Z = math.Max(highest.Z, p.Z)
+	}
+	return lowest, highest
+}
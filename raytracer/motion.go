@@ -0,0 +1,283 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// number of times sampled between keyframes when computing the bounds of a moving shape
+const motionBoundsSamples = 16
+
+// transform of a shape that changes over time, the shape is where it was defined at the start of the motion
+type Motion interface {
+	transformAt(time float64) Matrix4
+
+	// built from the parts of the motion, so hits don't invert a matrix
+	inverseTransformAt(time float64) Matrix4
+
+	// the motion can be inverted at every time in its range
+	validate() error
+
+	// the motion is clamped to this range, so the shape stays still before and after it
+	timeRange() (start float64, end float64)
+}
+
+// moves a shape at a constant velocity between Start and End
+type LinearMotion struct {
+	Velocity r3.Vec // world units per unit of time
+	Start    float64
+	End      float64
+}
+
+// moves a shape through keyframes, interpolating linearly between them
+// rotations are interpolated by slerp so they take the shortest path
+type KeyframedMotion struct {
+	Keyframes []MotionKeyframe
+	Pivot     r3.Vec // point the rotation and scale are around, eg the centroid of the shape
+}
+
+type MotionKeyframe struct {
+	Time        float64
+	Translation r3.Vec
+	Rotation    Quaternion
+	Scale       r3.Vec // the zero value is treated as no scaling
+}
+
+// wraps a shape that moves while the camera shutter is open, see Scene.CameraShutterOpen
+// rays are traced against the shape where it is at the time the ray was cast
+type MovingShape struct {
+	Shape  Shape
+	Motion Motion
+}
+
+// panics when the motion can't be inverted, eg keyframes scaling the shape to nothing
+func NewMovingShape(shape Shape, motion Motion) *MovingShape {
+	if err := motion.validate(); err != nil {
+		panic(err.Error())
+	}
+	return &MovingShape{Shape: shape, Motion: motion}
+}
+
+func (l LinearMotion) transformAt(time float64) Matrix4 {
+	time = math.Max(l.Start, math.Min(l.End, time))
+	return TranslationMatrix(r3.Scale(time-l.Start, l.Velocity))
+}
+
+func (l LinearMotion) inverseTransformAt(time float64) Matrix4 {
+	time = math.Max(l.Start, math.Min(l.End, time))
+	return TranslationMatrix(r3.Scale(l.Start-time, l.Velocity))
+}
+
+func (l LinearMotion) timeRange() (start float64, end float64) {
+	return l.Start, l.End
+}
+
+func (l LinearMotion) validate() error {
+	if l.End < l.Start {
+		return fmt.Errorf("linear motion ends at %f before it starts at %f", l.End, l.Start)
+	}
+	return nil
+}
+
+func (k KeyframedMotion) transformAt(time float64) Matrix4 {
+	return k.keyframeAt(time).matrix(k.Pivot)
+}
+
+func (k KeyframedMotion) inverseTransformAt(time float64) Matrix4 {
+	return k.keyframeAt(time).inverseMatrix(k.Pivot)
+}
+
+// interpolated between the keyframes around the time
+func (k KeyframedMotion) keyframeAt(time float64) MotionKeyframe {
+	keyframes := k.Keyframes
+	if len(keyframes) == 0 {
+		return MotionKeyframe{}
+	}
+	i := sort.Search(len(keyframes), func(i int) bool {
+		return keyframes[i].Time > time
+	})
+	if i == 0 {
+		return keyframes[0]
+	}
+	if i == len(keyframes) {
+		return keyframes[len(keyframes)-1]
+	}
+	from, to := keyframes[i-1], keyframes[i]
+	f := (time - from.Time) / (to.Time - from.Time)
+	return MotionKeyframe{
+		Translation: r3.Add(from.Translation, r3.Scale(f, r3.Sub(to.Translation, from.Translation))),
+		Rotation:    from.Rotation.Slerp(to.Rotation, f),
+		Scale:       r3.Add(from.scale(), r3.Scale(f, r3.Sub(to.scale(), from.scale()))),
+	}
+}
+
+func (k KeyframedMotion) timeRange() (start float64, end float64) {
+	if len(k.Keyframes) == 0 {
+		return 0, 0
+	}
+	return k.Keyframes[0].Time, k.Keyframes[len(k.Keyframes)-1].Time
+}
+
+// keyframes have to be in order of time, and each axis has to keep the same sign of scale, so the scale
+// interpolated between them never passes through 0
+func (k KeyframedMotion) validate() error {
+	for i, kf := range k.Keyframes {
+		s := kf.scale()
+		if s.X == 0 || s.Y == 0 || s.Z == 0 {
+			return fmt.Errorf("keyframe %d scales by %v, which can't be inverted", i, s)
+		}
+		if i == 0 {
+			continue
+		}
+		previous := k.Keyframes[i-1]
+		if kf.Time <= previous.Time {
+			return fmt.Errorf("keyframe %d at time %f is not after keyframe %d at time %f", i, kf.Time, i-1, previous.Time)
+		}
+		if p := previous.scale(); p.X*s.X < 0 || p.Y*s.Y < 0 || p.Z*s.Z < 0 {
+			return fmt.Errorf("keyframe %d scales by %v and keyframe %d by %v, the scale passes through 0 between them", i-1, p, i, s)
+		}
+	}
+	return nil
+}
+
+func (kf MotionKeyframe) scale() r3.Vec {
+	if kf.Scale == (r3.Vec{}) {
+		return r3.Vec{X: 1, Y: 1, Z: 1}
+	}
+	return kf.Scale
+}
+
+// scale then rotate around the pivot, then translate
+func (kf MotionKeyframe) matrix(pivot r3.Vec) Matrix4 {
+	return ComposeMatrices(
+		PivotMatrix(ScalingMatrix(kf.scale()), pivot),
+		PivotMatrix(kf.Rotation.Matrix(), pivot),
+		TranslationMatrix(kf.Translation),
+	)
+}
+
+// undoes matrix, translate back then rotate and scale the other way around the pivot
+func (kf MotionKeyframe) inverseMatrix(pivot r3.Vec) Matrix4 {
+	s := kf.scale()
+	return ComposeMatrices(
+		TranslationMatrix(r3.Scale(-1, kf.Translation)),
+		PivotMatrix(kf.Rotation.Conjugate().Matrix(), pivot),
+		PivotMatrix(ScalingMatrix(r3.Vec{X: 1 / s.X, Y: 1 / s.Y, Z: 1 / s.Z}), pivot),
+	)
+}
+
+func (ms MovingShape) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	transform := ms.Motion.transformAt(r.time)
+	inverse := ms.Motion.inverseTransformAt(r.time)
+	objectRay, scale := transformRay(&inverse, r)
+	hr := ms.Shape.hit(&objectRay, tMin*scale, tMax*scale)
+	if hr.t <= 0 {
+		return hitRecord{t: -1}
+	}
+	return transformHitRecord(r, &hr, scale, &transform, &inverse)
+}
+
+// keyframe pivots move along with the shape, so it still turns around the same point of it
+func (ms *MovingShape) Translate(tv r3.Vec) {
+	ms.Shape.Translate(tv)
+	if k, ok := ms.Motion.(KeyframedMotion); ok {
+		k.Pivot = r3.Add(k.Pivot, tv)
+		ms.Motion = k
+	}
+}
+
+// the motion is applied after scaling, so keyframe pivots are not moved
+func (ms *MovingShape) Scale(c float64) {
+	ms.Shape.Scale(c)
+}
+
+func (ms *MovingShape) Rotate(rv r3.Vec) {
+	ms.Shape.Rotate(rv)
+}
+
+// bounds enclosing the shape over the whole motion, so the bounding volume hierarchy finds it at any time
+func (ms MovingShape) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	pMin, pMax := ms.Shape.computeSquareBounds()
+	if isUnbounded(pMin, pMax) {
+		return pMin, pMax
+	}
+	start, end := ms.Motion.timeRange()
+	times := []float64{start, end}
+	paddings := []float64{0, 0}
+	if keyframed, ok := ms.Motion.(KeyframedMotion); ok {
+		times, paddings = keyframed.boundsSamples(pMin, pMax)
+	}
+
+	lowest = r3.Vec{X: math.MaxFloat64, Y: math.MaxFloat64, Z: math.MaxFloat64}
+	highest = r3.Vec{X: -math.MaxFloat64, Y: -math.MaxFloat64, Z: -math.MaxFloat64}
+	for i, time := range times {
+		transform := ms.Motion.transformAt(time)
+		l, h := transformBounds(&transform, pMin, pMax)
+		padding := r3.Vec{X: paddings[i], Y: paddings[i], Z: paddings[i]}
+		l = r3.Sub(l, padding)
+		h = r3.Add(h, padding)
+		lowest = r3.Vec{X: math.Min(lowest.X, l.X), Y: math.Min(lowest.Y, l.Y), Z: math.Min(lowest.Z, l.Z)}
+		highest = r3.Vec{X: math.Max(highest.X, h.X), Y: math.Max(highest.Y, h.Y), Z: math.Max(highest.Z, h.Z)}
+	}
+	return lowest, highest
+}
+
+// times to sample the bounds of a shape at, and how much to pad each of them
+// points rotating between two samples bulge out of the boxes at the samples by at most radius * angle / 2
+func (k KeyframedMotion) boundsSamples(pMin r3.Vec, pMax r3.Vec) (times []float64, paddings []float64) {
+	if len(k.Keyframes) == 0 {
+		return []float64{0}, []float64{0}
+	}
+	// furthest the shape reaches from the pivot it rotates around
+	farthest := r3.Vec{
+		X: math.Max(math.Abs(pMin.X-k.Pivot.X), math.Abs(pMax.X-k.Pivot.X)),
+		Y: math.Max(math.Abs(pMin.Y-k.Pivot.Y), math.Abs(pMax.Y-k.Pivot.Y)),
+		Z: math.Max(math.Abs(pMin.Z-k.Pivot.Z), math.Abs(pMax.Z-k.Pivot.Z)),
+	}
+	maxScale := 0.0
+	for _, kf := range k.Keyframes {
+		scale := kf.scale()
+		maxScale = math.Max(maxScale, math.Max(math.Abs(scale.X), math.Max(math.Abs(scale.Y), math.Abs(scale.Z))))
+	}
+	radius := maxScale * r3.Norm(farthest)
+
+	for i, kf := range k.Keyframes {
+		if i+1 == len(k.Keyframes) {
+			times = append(times, kf.Time)
+			paddings = append(paddings, 0)
+			break
+		}
+		next := k.Keyframes[i+1]
+		from, to := kf.Rotation.Normalize(), next.Rotation.Normalize()
+		cosHalfAngle := math.Min(1, math.Abs(from.W*to.W+from.X*to.X+from.Y*to.Y+from.Z*to.Z))
+		stepAngle := 2 * math.Acos(cosHalfAngle) / motionBoundsSamples
+		step := (next.Time - kf.Time) / motionBoundsSamples
+		for s := 0; s < motionBoundsSamples; s++ {
+			times = append(times, kf.Time+float64(s)*step)
+			paddings = append(paddings, 0.5*radius*stepAngle)
+		}
+	}
+	return times, paddings
+}
+
+func (ms MovingShape) centroid() r3.Vec {
+	lowest, highest := ms.computeSquareBounds()
+	return r3.Scale(0.5, r3.Add(lowest, highest))
+}
+
+// texture lookups happen on the wrapped shape, see transformHitRecord
+func (ms MovingShape) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	return ms.Shape.textureMap(point, normal)
+}
+
+func (ms MovingShape) description() string {
+	return fmt.Sprintf(
+		"%s - Shape: %s, Motion: %s",
+		reflect.TypeOf(ms),
+		ms.Shape.description(),
+		reflect.TypeOf(ms.Motion),
+	)
+}
@@ -0,0 +1,81 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestMovingShape(t *testing.T) {
+	// sphere moving from x=0 to x=4 while the shutter is open
+	moving := NewMovingShape(&Sphere{Center: r3.Vec{}, Radius: 1}, LinearMotion{Velocity: r3.Vec{X: 4}, Start: 0, End: 1})
+	for _, test := range []struct{ time, x float64 }{{0, 0}, {0.5, 2}, {1, 4}, {2, 4}} {
+		r := ray{p: r3.Vec{X: test.x, Y: 10}, normalizedDirection: r3.Vec{Y: -1}, time: test.time}
+		hr := moving.hit(&r, 0, math.MaxFloat64)
+		if math.Abs(hr.t-9) > 1e-9 || r3.Norm(r3.Sub(hr.normal, r3.Vec{Y: 1})) > 1e-9 {
+			t.Errorf("expected hit at t=9 with normal {0 1 0} at time %f, got t=%f with normal %v", test.time, hr.t, hr.normal)
+		}
+	}
+	lowest, highest := moving.computeSquareBounds()
+	if r3.Norm(r3.Sub(lowest, r3.Vec{X: -1, Y: -1, Z: -1})) > 1e-9 || r3.Norm(r3.Sub(highest, r3.Vec{X: 5, Y: 1, Z: 1})) > 1e-9 {
+		t.Errorf("expected bounds {-1 -1 -1} {5 1 1}, got %v %v", lowest, highest)
+	}
+
+	// box spinning half a turn around the y axis, its bounds must enclose it at every time in between
+	spinning := NewMovingShape(&Box{Min: r3.Vec{X: 1, Y: -0.5, Z: -0.5}, Max: r3.Vec{X: 3, Y: 0.5, Z: 0.5}}, KeyframedMotion{Keyframes: []MotionKeyframe{
+		{Time: 0, Rotation: IdentityQuaternion()},
+		{Time: 1, Rotation: QuaternionFromAxisAngle(r3.Vec{Y: 1}, 179)},
+	}})
+	lowest, highest = spinning.computeSquareBounds()
+	for i := 0; i <= 100; i++ {
+		transform := spinning.Motion.transformAt(float64(i) / 100)
+		l, h := transformBounds(&transform, r3.Vec{X: 1, Y: -0.5, Z: -0.5}, r3.Vec{X: 3, Y: 0.5, Z: 0.5})
+		if l.X < lowest.X || l.Y < lowest.Y || l.Z < lowest.Z || h.X > highest.X || h.Y > highest.Y || h.Z > highest.Z {
+			t.Fatalf("bounds %v %v don't enclose the box at time %f: %v %v", lowest, highest, float64(i)/100, l, h)
+		}
+	}
+
+	// moved away, the box still spins around the same point of it
+	tv := r3.Vec{Y: 2, Z: 10}
+	spinning.Translate(tv)
+	movedLowest, movedHighest := spinning.computeSquareBounds()
+	if r3.Norm(r3.Sub(movedLowest, r3.Add(lowest, tv))) > 1e-9 || r3.Norm(r3.Sub(movedHighest, r3.Add(highest, tv))) > 1e-9 {
+		t.Errorf("expected the bounds of the translated box to be %v %v, got %v %v", r3.Add(lowest, tv), r3.Add(highest, tv), movedLowest, movedHighest)
+	}
+}
+
+func TestKeyframedMotionInverse(t *testing.T) {
+	motion := KeyframedMotion{
+		Keyframes: []MotionKeyframe{
+			{Time: 0, Rotation: IdentityQuaternion(), Scale: r3.Vec{X: 1, Y: 2, Z: -1}},
+			{Time: 1, Translation: r3.Vec{X: 3, Y: -1}, Rotation: QuaternionFromAxisAngle(r3.Vec{X: 1, Y: 1}, 90), Scale: r3.Vec{X: 0.5, Y: 1, Z: -2}},
+		},
+		Pivot: r3.Vec{X: 1, Y: 2, Z: 3},
+	}
+	if err := motion.validate(); err != nil {
+		t.Fatal(err)
+	}
+	p := r3.Vec{X: 0.3, Y: -2, Z: 5}
+	for _, time := range []float64{-1, 0, 0.25, 0.5, 1, 2} {
+		transform, inverse := motion.transformAt(time), motion.inverseTransformAt(time)
+		if back := inverse.TransformPoint(transform.TransformPoint(p)); r3.Norm(r3.Sub(back, p)) > 1e-9 {
+			t.Errorf("expected the inverse to undo the transform at time %f, got %v back from %v", time, back, p)
+		}
+	}
+
+	for name, keyframes := range map[string][]MotionKeyframe{
+		"zero scale":         {{Time: 0, Scale: r3.Vec{X: 1, Y: 0, Z: 1}}},
+		"scale through zero": {{Time: 0}, {Time: 1, Scale: r3.Vec{X: -1, Y: 1, Z: 1}}},
+		"out of order":       {{Time: 1}, {Time: 0}},
+		"same time":          {{Time: 1}, {Time: 1}},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected keyframes with %s to panic", name)
+				}
+			}()
+			NewMovingShape(&Sphere{Radius: 1}, KeyframedMotion{Keyframes: keyframes})
+		}()
+	}
+}
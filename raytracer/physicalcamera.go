@@ -0,0 +1,92 @@
+package raytracer
+
+import (
+	"math"
+)
+
+// exposure settings that render the image as it is, brighter or darker settings scale the image by the exposure
+const referenceFNumber = 4.0
+const referenceShutterSpeed = 1.0 / 60.0
+const referenceISO = 100.0
+
+// lens of a full frame camera, used for the lens settings left at 0
+const defaultSensorWidth = 36.0
+const defaultFocalLength = 50.0
+
+// camera described the way a photographer would, used instead of CameraFov and CameraAperature when set on a Scene
+// the exposure settings left at 0 default to the reference settings, so the image renders as it is,
+// and the lens settings left at 0 default to a 50mm lens on a full frame sensor
+type PhysicalCamera struct {
+	SensorWidth  float64 // in millimetres, the sensor height follows the aspect ratio of the image, defaults to 36 (full frame)
+	FocalLength  float64 // in millimetres, defaults to 50
+	FNumber      float64 // eg 2.8 for f/2.8, defaults to f/4
+	ShutterSpeed float64 // in seconds, also how long the shutter is open for motion blur, defaults to 1/60s
+	ISO          float64 // defaults to 100
+	// size of the scene, defaults to 1 world unit per metre
+	WorldUnitsPerMetre float64
+}
+
+func (pc PhysicalCamera) withDefaults() PhysicalCamera {
+	if pc.SensorWidth <= 0 {
+		pc.SensorWidth = defaultSensorWidth
+	}
+	if pc.FocalLength <= 0 {
+		pc.FocalLength = defaultFocalLength
+	}
+	if pc.FNumber <= 0 {
+		pc.FNumber = referenceFNumber
+	}
+	if pc.ShutterSpeed <= 0 {
+		pc.ShutterSpeed = referenceShutterSpeed
+	}
+	if pc.ISO <= 0 {
+		pc.ISO = referenceISO
+	}
+	if pc.WorldUnitsPerMetre <= 0 {
+		pc.WorldUnitsPerMetre = 1
+	}
+	return pc
+}
+
+// vertical field of view in degrees, like CameraFov
+func (pc PhysicalCamera) fov(aspect float64) float64 {
+	pc = pc.withDefaults()
+	sensorHeight := pc.SensorWidth / aspect
+	return 2 * math.Atan(sensorHeight/(2*pc.FocalLength)) * 180 / math.Pi
+}
+
+// diameter of the opening of the lens in world units, like CameraAperature
+func (pc PhysicalCamera) aperature() float64 {
+	pc = pc.withDefaults()
+	return pc.FocalLength / pc.FNumber / 1000 * pc.WorldUnitsPerMetre
+}
+
+// how much brighter the image is than with the reference settings of f/4, 1/60s and ISO 100
+// light reaching the sensor goes up with the time the shutter is open and the area of the aperature
+func (pc PhysicalCamera) exposure() float64 {
+	pc = pc.withDefaults()
+	return (pc.ShutterSpeed / (pc.FNumber * pc.FNumber) * pc.ISO) / (referenceShutterSpeed / (referenceFNumber * referenceFNumber) * referenceISO)
+}
+
+// field of view and aperature the camera is built with, from the physical camera when there is one
+func (s Scene) lens(aspect float64) (fov float64, aperature float64) {
+	if s.PhysicalCamera != nil {
+		return s.PhysicalCamera.fov(aspect), s.PhysicalCamera.aperature()
+	}
+	return s.CameraFov, s.CameraAperature
+}
+
+func (s Scene) exposure() float64 {
+	if s.PhysicalCamera != nil {
+		return s.PhysicalCamera.exposure()
+	}
+	return 1
+}
+
+// time the shutter is open for, the physical camera keeps it open for its shutter speed
+func (s Scene) shutter() (open float64, close float64) {
+	if s.PhysicalCamera != nil {
+		return s.CameraShutterOpen, s.CameraShutterOpen + s.PhysicalCamera.withDefaults().ShutterSpeed
+	}
+	return s.CameraShutterOpen, s.CameraShutterClose
+}
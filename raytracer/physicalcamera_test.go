@@ -0,0 +1,55 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPhysicalCamera(t *testing.T) {
+	// 50mm lens on a full frame sensor, with a 3:2 image the sensor is 24mm high
+	pc := PhysicalCamera{SensorWidth: 36, FocalLength: 50, FNumber: 2, ShutterSpeed: 1.0 / 60, ISO: 100}
+	scene := Scene{PhysicalCamera: &pc, CameraFov: 90, CameraAperature: 1}
+	fov, aperature := scene.lens(1.5)
+	if expected := 2 * math.Atan(12.0/50) * 180 / math.Pi; math.Abs(fov-expected) > 1e-9 {
+		t.Errorf("expected fov %f, got %f", expected, fov)
+	}
+	if math.Abs(aperature-0.025) > 1e-9 {
+		t.Errorf("expected a 25mm aperature, got %f", aperature)
+	}
+	// f/2 lets in 4 times as much light as f/4
+	if exposure := scene.exposure(); math.Abs(exposure-4) > 1e-9 {
+		t.Errorf("expected exposure 4, got %f", exposure)
+	}
+	if open, close := scene.shutter(); open != 0 || math.Abs(close-1.0/60) > 1e-9 {
+		t.Errorf("expected the shutter open for 1/60s, got %f to %f", open, close)
+	}
+
+	// a stop faster shutter and a stop higher iso cancel out
+	pc = PhysicalCamera{SensorWidth: 36, FocalLength: 50, FNumber: 4, ShutterSpeed: 1.0 / 120, ISO: 200}
+	if exposure := scene.exposure(); math.Abs(exposure-1) > 1e-9 {
+		t.Errorf("expected exposure 1, got %f", exposure)
+	}
+
+	// only the lens set, the exposure settings are the reference ones
+	pc = PhysicalCamera{SensorWidth: 36, FocalLength: 50}
+	if exposure := scene.exposure(); math.Abs(exposure-1) > 1e-9 {
+		t.Errorf("expected exposure 1 with the default settings, got %f", exposure)
+	}
+	if _, aperature := scene.lens(1.5); math.Abs(aperature-0.0125) > 1e-9 {
+		t.Errorf("expected a 12.5mm aperature at f/4, got %f", aperature)
+	}
+	if _, close := scene.shutter(); math.Abs(close-referenceShutterSpeed) > 1e-9 {
+		t.Errorf("expected the shutter open for 1/60s, got %f", close)
+	}
+
+	// nothing set is a 50mm lens on a full frame sensor at the reference settings
+	pc = PhysicalCamera{}
+	if fov, aperature := scene.lens(1.5); math.Abs(fov-2*math.Atan(12.0/50)*180/math.Pi) > 1e-9 || math.Abs(aperature-0.0125) > 1e-9 {
+		t.Errorf("expected the fov and aperature of a 50mm lens on a full frame sensor, got fov %f and aperature %f", fov, aperature)
+	}
+
+	scene.PhysicalCamera = nil
+	if fov, aperature := scene.lens(1.5); fov != 90 || aperature != 1 || scene.exposure() != 1 {
+		t.Errorf("expected the simple camera fields without a physical camera, got fov %f and aperature %f", fov, aperature)
+	}
+}
@@ -0,0 +1,143 @@
+package raytracer
+
+import (
+	"math"
+	"sort"
+)
+
+// closed form polynomial root solvers, ported from "Roots3And4.c" by Jochen Schwarze (Graphics Gems I)
+// coefficients are ordered from the constant term upwards, ie c[0] + c[1]x + c[2]x^2 + ...
+
+const polynomialEpsilon = 1e-9
+
+func isNearZero(x float64) bool {
+	return x > -polynomialEpsilon && x < polynomialEpsilon
+}
+
+func solveQuadratic(c [3]float64) []float64 {
+	p := c[1] / (2 * c[2])
+	q := c[0] / c[2]
+	d := p*p - q
+
+	if isNearZero(d) {
+		return []float64{-p}
+	} else if d < 0 {
+		return nil
+	}
+	sqrtD := math.Sqrt(d)
+	return []float64{sqrtD - p, -sqrtD - p}
+}
+
+func solveCubic(c [4]float64) []float64 {
+	// normal form x^3 + Ax^2 + Bx + C = 0
+	a := c[2] / c[3]
+	b := c[1] / c[3]
+	cc := c[0] / c[3]
+
+	// substitute x = y - A/3 to eliminate quadric term, y^3 + 3py + 2q = 0
+	sqA := a * a
+	p := 1.0 / 3 * (-1.0/3*sqA + b)
+	q := 1.0 / 2 * (2.0/27*a*sqA - 1.0/3*a*b + cc)
+
+	// use Cardano's formula
+	cbP := p * p * p
+	d := q*q + cbP
+
+	var s []float64
+	if isNearZero(d) {
+		if isNearZero(q) {
+			s = []float64{0}
+		} else {
+			u := math.Cbrt(-q)
+			s = []float64{2 * u, -u}
+		}
+	} else if d < 0 {
+		// casus irreducibilis, three real solutions
+		phi := 1.0 / 3 * math.Acos(-q/math.Sqrt(-cbP))
+		t := 2 * math.Sqrt(-p)
+		s = []float64{
+			t * math.Cos(phi),
+			-t * math.Cos(phi+math.Pi/3),
+			-t * math.Cos(phi-math.Pi/3),
+		}
+	} else {
+		sqrtD := math.Sqrt(d)
+		u := math.Cbrt(sqrtD - q)
+		v := -math.Cbrt(sqrtD + q)
+		s = []float64{u + v}
+	}
+
+	sub := 1.0 / 3 * a
+	for i := range s {
+		s[i] -= sub
+	}
+	return s
+}
+
+// returns the real roots in ascending order
+func solveQuartic(c [5]float64) []float64 {
+	// normal form x^4 + Ax^3 + Bx^2 + Cx + D = 0
+	a := c[3] / c[4]
+	b := c[2] / c[4]
+	cc := c[1] / c[4]
+	d := c[0] / c[4]
+
+	// substitute x = y - A/4 to eliminate cubic term, y^4 + py^2 + qy + r = 0
+	sqA := a * a
+	p := -3.0/8*sqA + b
+	q := 1.0/8*sqA*a - 1.0/2*a*b + cc
+	r := -3.0/256*sqA*sqA + 1.0/16*sqA*b - 1.0/4*a*cc + d
+
+	var s []float64
+	if isNearZero(r) {
+		// no absolute term, y(y^3 + py + q) = 0
+		s = append(solveCubic([4]float64{q, p, 0, 1}), 0)
+	} else {
+		// solve the resolvent cubic and take the one real solution
+		z := solveCubic([4]float64{1.0/2*r*p - 1.0/8*q*q, -r, -1.0 / 2 * p, 1})[0]
+
+		// build two quadric equations
+		u := z*z - r
+		v := 2*z - p
+		if isNearZero(u) {
+			u = 0
+		} else if u > 0 {
+			u = math.Sqrt(u)
+		} else {
+			return nil
+		}
+		if isNearZero(v) {
+			v = 0
+		} else if v > 0 {
+			v = math.Sqrt(v)
+		} else {
+			return nil
+		}
+
+		signedV := v
+		if q < 0 {
+			signedV = -v
+		}
+		s = append(solveQuadratic([3]float64{z - u, signedV, 1}), solveQuadratic([3]float64{z + u, -signedV, 1})...)
+	}
+
+	sub := 1.0 / 4 * a
+	for i := range s {
+		s[i] = polishRoot(c, s[i]-sub)
+	}
+	sort.Float64s(s)
+	return s
+}
+
+// a couple of newton iterations to reduce the error introduced by the closed form solution
+func polishRoot(c [5]float64, x float64) float64 {
+	for i := 0; i < 2; i++ {
+		f := (((c[4]*x+c[3])*x+c[2])*x+c[1])*x + c[0]
+		df := ((4*c[4]*x+3*c[3])*x+2*c[2])*x + c[1]
+		if df == 0 {
+			break
+		}
+		x -= f / df
+	}
+	return x
+}
@@ -0,0 +1,766 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+)
+
+// infinite plane going through Point
+type Plane struct {
+	Point       r3.Vec
+	Normal      r3.Vec
+	SingleSided bool
+	Mat         Material
+}
+
+// flat circle facing Normal
+type Disk struct {
+	Center r3.Vec
+	Normal r3.Vec
+	Radius float64
+	Mat    Material
+}
+
+// axis aligned box, until it is rotated
+type Box struct {
+	Min         r3.Vec
+	Max         r3.Vec
+	Orientation Quaternion // around the center of the box, set by Rotate, the zero value keeps it axis aligned
+	Mat         Material
+}
+
+// box rotated around its center by Orientation
+type OrientedBox struct {
+	Center      r3.Vec
+	HalfSize    r3.Vec
+	Orientation Quaternion
+	Mat         Material
+}
+
+// cylinder between the centers of its two ends, optionally closed off with caps
+type Cylinder struct {
+	BaseCenter r3.Vec
+	TopCenter  r3.Vec
+	Radius     float64
+	Capped     bool
+	Mat        Material
+}
+
+// cone narrowing from a circular base with Radius to a point at Apex, optionally closed off with a cap on the base
+type Cone struct {
+	BaseCenter r3.Vec
+	Apex       r3.Vec
+	Radius     float64
+	Capped     bool
+	Mat        Material
+}
+
+// ring around Axis, MajorRadius is from the center to the middle of the tube and MinorRadius is the radius of the tube
+type Torus struct {
+	Center      r3.Vec
+	Axis        r3.Vec
+	MajorRadius float64
+	MinorRadius float64
+	Mat         Material
+}
+
+// intersection of a ray with the surface of a shape, the normal is in world space and points outwards
+type surfaceHit struct {
+	t      float64
+	normal r3.Vec
+}
+
+func (p Plane) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	n := r3.Unit(p.Normal)
+	denom := r3.Dot(n, r.normalizedDirection)
+	// hitting the back of the plane, or parallel to the plane
+	if (p.SingleSided && denom > 0) || math.Abs(denom) < 1e-12 {
+		return hitRecord{t: -1}
+	}
+	t := r3.Dot(r3.Sub(p.Point, r.p), n) / denom
+	if t < tMin || t > tMax {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        t,
+		p:        r.PointAtT(t),
+		normal:   n,
+		shape:    &p,
+		material: p.Mat,
+	}
+}
+
+func (p *Plane) Translate(tv r3.Vec) {
+	p.Point = r3.Add(tv, p.Point)
+}
+
+// planes are infinite, so scaling around their centroid does nothing
+func (p *Plane) Scale(c float64) {
+}
+
+func (p *Plane) Rotate(rv r3.Vec) {
+	p.Point = rotatePoint(p.Point, rv)
+	p.Normal = rotatePoint(p.Normal, rv)
+}
+
+func (p Plane) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	return r3.Vec{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}, r3.Vec{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+}
+
+func (p Plane) centroid() r3.Vec {
+	return p.Point
+}
+
+// texture repeats every world unit along the plane
+func (p Plane) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	e1, e2 := orthonormalBasis(r3.Unit(p.Normal))
+	onPlane := r3.Sub(point, p.Point)
+	x := r3.Dot(onPlane, e1)
+	y := r3.Dot(onPlane, e2)
+	return x - math.Floor(x), y - math.Floor(y)
+}
+
+func (p Plane) description() string {
+	return fmt.Sprintf(
+		"%s - Point: %v, Normal: %v, Material: %s",
+		reflect.TypeOf(p),
+		p.Point,
+		p.Normal,
+		reflect.TypeOf(p.Mat),
+	)
+}
+
+func (d Disk) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	n := r3.Unit(d.Normal)
+	denom := r3.Dot(n, r.normalizedDirection)
+	if math.Abs(denom) < 1e-12 {
+		return hitRecord{t: -1}
+	}
+	t := r3.Dot(r3.Sub(d.Center, r.p), n) / denom
+	if t < tMin || t > tMax {
+		return hitRecord{t: -1}
+	}
+	p := r.PointAtT(t)
+	fromCenter := r3.Sub(p, d.Center)
+	if r3.Dot(fromCenter, fromCenter) > d.Radius*d.Radius {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        t,
+		p:        p,
+		normal:   n,
+		shape:    &d,
+		material: d.Mat,
+	}
+}
+
+func (d *Disk) Translate(tv r3.Vec) {
+	d.Center = r3.Add(tv, d.Center)
+}
+
+func (d *Disk) Scale(c float64) {
+	d.Radius *= c
+}
+
+func (d *Disk) Rotate(rv r3.Vec) {
+	d.Center = rotatePoint(d.Center, rv)
+	d.Normal = rotatePoint(d.Normal, rv)
+}
+
+func (d Disk) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	// extent of a circle along each axis is radius * sqrt(1 - n^2)
+	n := r3.Unit(d.Normal)
+	extent := r3.Vec{
+		X: d.Radius * math.Sqrt(math.Max(0, 1-n.X*n.X)),
+		Y: d.Radius * math.Sqrt(math.Max(0, 1-n.Y*n.Y)),
+		Z: d.Radius * math.Sqrt(math.Max(0, 1-n.Z*n.Z)),
+	}
+	return r3.Sub(d.Center, extent), r3.Add(d.Center, extent)
+}
+
+func (d Disk) centroid() r3.Vec {
+	return d.Center
+}
+
+// u goes around the disk, v goes from the center to the edge
+func (d Disk) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	e1, e2 := orthonormalBasis(r3.Unit(d.Normal))
+	fromCenter := r3.Sub(point, d.Center)
+	x := r3.Dot(fromCenter, e1)
+	y := r3.Dot(fromCenter, e2)
+	return (math.Atan2(y, x) + math.Pi) / (2 * math.Pi), math.Min(1, math.Sqrt(x*x+y*y)/d.Radius)
+}
+
+func (d Disk) description() string {
+	return fmt.Sprintf(
+		"%s - Center: %v, Normal: %v, Radius: %f, Material: %s",
+		reflect.TypeOf(d),
+		d.Center,
+		d.Normal,
+		d.Radius,
+		reflect.TypeOf(d.Mat),
+	)
+}
+
+func (b Box) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	sh, ok := closestSurfaceHit(b.surfaceHits(r), tMin, tMax)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        sh.t,
+		p:        r.PointAtT(sh.t),
+		normal:   sh.normal,
+		shape:    &b,
+		material: b.Mat,
+	}
+}
+
+func (b Box) surfaceHits(r *ray) []surfaceHit {
+	if b.rotated() {
+		return b.orientedBox().surfaceHits(r)
+	}
+	tNear, tFar, ok := intersectSlabs(r.p, r.normalizedDirection, b.Min, b.Max)
+	if !ok {
+		return nil
+	}
+	return []surfaceHit{
+		{t: tNear, normal: boxNormal(r.PointAtT(tNear), b.Min, b.Max)},
+		{t: tFar, normal: boxNormal(r.PointAtT(tFar), b.Min, b.Max)},
+	}
+}
+
+func (b *Box) Translate(tv r3.Vec) {
+	b.Min = r3.Add(tv, b.Min)
+	b.Max = r3.Add(tv, b.Max)
+}
+
+// scales around the center of the box
+func (b *Box) Scale(c float64) {
+	center := b.centroid()
+	b.Min = r3.Add(center, r3.Scale(c, r3.Sub(b.Min, center)))
+	b.Max = r3.Add(center, r3.Scale(c, r3.Sub(b.Max, center)))
+}
+
+// like an OrientedBox, the center is rotated around the origin and Min and Max stay the corners before the rotation
+func (b *Box) Rotate(rv r3.Vec) {
+	center := b.centroid()
+	b.Translate(r3.Sub(rotatePoint(center, rv), center))
+	b.Orientation = QuaternionFromEuler(rv).Multiply(b.Orientation)
+}
+
+func (b Box) rotated() bool {
+	return b.Orientation.orIdentity() != IdentityQuaternion()
+}
+
+func (b Box) orientedBox() OrientedBox {
+	return OrientedBox{Center: b.centroid(), HalfSize: r3.Scale(0.5, r3.Sub(b.Max, b.Min)), Orientation: b.Orientation, Mat: b.Mat}
+}
+
+func (b Box) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	if b.rotated() {
+		return b.orientedBox().computeSquareBounds()
+	}
+	return b.Min, b.Max
+}
+
+func (b Box) centroid() r3.Vec {
+	return r3.Scale(0.5, r3.Add(b.Min, b.Max))
+}
+
+func (b Box) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	if b.rotated() {
+		return b.orientedBox().textureMap(point, normal)
+	}
+	return boxTextureMap(point, normal, b.Min, b.Max)
+}
+
+func (b Box) description() string {
+	return fmt.Sprintf(
+		"%s - Min: %v, Max: %v, Orientation: %v, Material: %s",
+		reflect.TypeOf(b),
+		b.Min,
+		b.Max,
+		b.Orientation,
+		reflect.TypeOf(b.Mat),
+	)
+}
+
+func (ob OrientedBox) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	sh, ok := closestSurfaceHit(ob.surfaceHits(r), tMin, tMax)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        sh.t,
+		p:        r.PointAtT(sh.t),
+		normal:   sh.normal,
+		shape:    &ob,
+		material: ob.Mat,
+	}
+}
+
+func (ob OrientedBox) surfaceHits(r *ray) []surfaceHit {
+	toLocal := ob.Orientation.Conjugate()
+	o := toLocal.Rotate(r3.Sub(r.p, ob.Center))
+	d := toLocal.Rotate(r.normalizedDirection)
+	pMin := r3.Scale(-1, ob.HalfSize)
+	tNear, tFar, ok := intersectSlabs(o, d, pMin, ob.HalfSize)
+	if !ok {
+		return nil
+	}
+	return []surfaceHit{
+		{t: tNear, normal: ob.Orientation.Rotate(boxNormal(r3.Add(o, r3.Scale(tNear, d)), pMin, ob.HalfSize))},
+		{t: tFar, normal: ob.Orientation.Rotate(boxNormal(r3.Add(o, r3.Scale(tFar, d)), pMin, ob.HalfSize))},
+	}
+}
+
+func (ob *OrientedBox) Translate(tv r3.Vec) {
+	ob.Center = r3.Add(tv, ob.Center)
+}
+
+func (ob *OrientedBox) Scale(c float64) {
+	ob.HalfSize = r3.Scale(c, ob.HalfSize)
+}
+
+func (ob *OrientedBox) Rotate(rv r3.Vec) {
+	ob.Center = rotatePoint(ob.Center, rv)
+	ob.Orientation = QuaternionFromEuler(rv).Multiply(ob.Orientation)
+}
+
+func (ob OrientedBox) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	m := TranslationMatrix(ob.Center).Multiply(ob.Orientation.Matrix())
+	return transformBounds(&m, r3.Scale(-1, ob.HalfSize), ob.HalfSize)
+}
+
+func (ob OrientedBox) centroid() r3.Vec {
+	return ob.Center
+}
+
+func (ob OrientedBox) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	toLocal := ob.Orientation.Conjugate()
+	return boxTextureMap(toLocal.Rotate(r3.Sub(point, ob.Center)), toLocal.Rotate(normal), r3.Scale(-1, ob.HalfSize), ob.HalfSize)
+}
+
+func (ob OrientedBox) description() string {
+	return fmt.Sprintf(
+		"%s - Center: %v, Half Size: %v, Orientation: %v, Material: %s",
+		reflect.TypeOf(ob),
+		ob.Center,
+		ob.HalfSize,
+		ob.Orientation,
+		reflect.TypeOf(ob.Mat),
+	)
+}
+
+func (cy Cylinder) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	sh, ok := closestSurfaceHit(cy.surfaceHits(r), tMin, tMax)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        sh.t,
+		p:        r.PointAtT(sh.t),
+		normal:   sh.normal,
+		shape:    &cy,
+		material: cy.Mat,
+	}
+}
+
+func (cy Cylinder) surfaceHits(r *ray) []surfaceHit {
+	frame := newLocalFrame(cy.BaseCenter, cy.TopCenter)
+	o, d := frame.toLocal(r.p), frame.toLocalDirection(r.normalizedDirection)
+	hits := make([]surfaceHit, 0, 4)
+
+	// side, x^2 + y^2 = radius^2
+	a := d.X*d.X + d.Y*d.Y
+	b := o.X*d.X + o.Y*d.Y
+	c := o.X*o.X + o.Y*o.Y - cy.Radius*cy.Radius
+	discriminant := b*b - a*c
+	if a > 1e-12 && discriminant >= 0 {
+		sqrtDiscriminant := math.Sqrt(discriminant)
+		for _, t := range []float64{(-b - sqrtDiscriminant) / a, (-b + sqrtDiscriminant) / a} {
+			z := o.Z + t*d.Z
+			if z >= 0 && z <= frame.height {
+				hits = append(hits, surfaceHit{t: t, normal: frame.toWorldDirection(r3.Vec{X: o.X + t*d.X, Y: o.Y + t*d.Y})})
+			}
+		}
+	}
+
+	if cy.Capped {
+		hits = appendCapHit(hits, o, d, 0, cy.Radius, frame.toWorldDirection(r3.Vec{Z: -1}))
+		hits = appendCapHit(hits, o, d, frame.height, cy.Radius, frame.toWorldDirection(r3.Vec{Z: 1}))
+	}
+	return sortSurfaceHits(hits)
+}
+
+func (cy *Cylinder) Translate(tv r3.Vec) {
+	cy.BaseCenter = r3.Add(tv, cy.BaseCenter)
+	cy.TopCenter = r3.Add(tv, cy.TopCenter)
+}
+
+// scales around the center of the cylinder
+func (cy *Cylinder) Scale(c float64) {
+	center := cy.centroid()
+	cy.BaseCenter = r3.Add(center, r3.Scale(c, r3.Sub(cy.BaseCenter, center)))
+	cy.TopCenter = r3.Add(center, r3.Scale(c, r3.Sub(cy.TopCenter, center)))
+	cy.Radius *= c
+}
+
+func (cy *Cylinder) Rotate(rv r3.Vec) {
+	cy.BaseCenter = rotatePoint(cy.BaseCenter, rv)
+	cy.TopCenter = rotatePoint(cy.TopCenter, rv)
+}
+
+func (cy Cylinder) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	frame := newLocalFrame(cy.BaseCenter, cy.TopCenter)
+	return frame.bounds(r3.Vec{X: -cy.Radius, Y: -cy.Radius}, r3.Vec{X: cy.Radius, Y: cy.Radius, Z: frame.height})
+}
+
+func (cy Cylinder) centroid() r3.Vec {
+	return r3.Scale(0.5, r3.Add(cy.BaseCenter, cy.TopCenter))
+}
+
+// u goes around the cylinder, v goes from the base to the top
+func (cy Cylinder) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	frame := newLocalFrame(cy.BaseCenter, cy.TopCenter)
+	local := frame.toLocal(point)
+	return (math.Atan2(local.Y, local.X) + math.Pi) / (2 * math.Pi), saturate(local.Z / frame.height)
+}
+
+func (cy Cylinder) description() string {
+	return fmt.Sprintf(
+		"%s - Base Center: %v, Top Center: %v, Radius: %f, Capped: %t, Material: %s",
+		reflect.TypeOf(cy),
+		cy.BaseCenter,
+		cy.TopCenter,
+		cy.Radius,
+		cy.Capped,
+		reflect.TypeOf(cy.Mat),
+	)
+}
+
+func (co Cone) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	sh, ok := closestSurfaceHit(co.surfaceHits(r), tMin, tMax)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        sh.t,
+		p:        r.PointAtT(sh.t),
+		normal:   sh.normal,
+		shape:    &co,
+		material: co.Mat,
+	}
+}
+
+func (co Cone) surfaceHits(r *ray) []surfaceHit {
+	frame := newLocalFrame(co.BaseCenter, co.Apex)
+	o, d := frame.toLocal(r.p), frame.toLocalDirection(r.normalizedDirection)
+	hits := make([]surfaceHit, 0, 3)
+
+	// side, x^2 + y^2 = (k(h - z))^2 where k is the slope of the side
+	k := co.Radius / frame.height
+	kSqrd := k * k
+	h := frame.height - o.Z
+	a := d.X*d.X + d.Y*d.Y - kSqrd*d.Z*d.Z
+	b := o.X*d.X + o.Y*d.Y + kSqrd*h*d.Z
+	c := o.X*o.X + o.Y*o.Y - kSqrd*h*h
+	var roots []float64
+	if math.Abs(a) < 1e-12 {
+		if math.Abs(b) > 1e-12 {
+			roots = []float64{-c / (2 * b)}
+		}
+	} else if discriminant := b*b - a*c; discriminant >= 0 {
+		sqrtDiscriminant := math.Sqrt(discriminant)
+		roots = []float64{(-b - sqrtDiscriminant) / a, (-b + sqrtDiscriminant) / a}
+	}
+	for _, t := range roots {
+		p := r3.Add(o, r3.Scale(t, d))
+		if p.Z >= 0 && p.Z <= frame.height {
+			hits = append(hits, surfaceHit{t: t, normal: frame.toWorldDirection(r3.Vec{X: p.X, Y: p.Y, Z: kSqrd * (frame.height - p.Z)})})
+		}
+	}
+
+	if co.Capped {
+		hits = appendCapHit(hits, o, d, 0, co.Radius, frame.toWorldDirection(r3.Vec{Z: -1}))
+	}
+	return sortSurfaceHits(hits)
+}
+
+func (co *Cone) Translate(tv r3.Vec) {
+	co.BaseCenter = r3.Add(tv, co.BaseCenter)
+	co.Apex = r3.Add(tv, co.Apex)
+}
+
+// scales around the middle of the cone's axis
+func (co *Cone) Scale(c float64) {
+	center := co.centroid()
+	co.BaseCenter = r3.Add(center, r3.Scale(c, r3.Sub(co.BaseCenter, center)))
+	co.Apex = r3.Add(center, r3.Scale(c, r3.Sub(co.Apex, center)))
+	co.Radius *= c
+}
+
+func (co *Cone) Rotate(rv r3.Vec) {
+	co.BaseCenter = rotatePoint(co.BaseCenter, rv)
+	co.Apex = rotatePoint(co.Apex, rv)
+}
+
+func (co Cone) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	frame := newLocalFrame(co.BaseCenter, co.Apex)
+	return frame.bounds(r3.Vec{X: -co.Radius, Y: -co.Radius}, r3.Vec{X: co.Radius, Y: co.Radius, Z: frame.height})
+}
+
+func (co Cone) centroid() r3.Vec {
+	return r3.Scale(0.5, r3.Add(co.BaseCenter, co.Apex))
+}
+
+// u goes around the cone, v goes from the base to the apex
+func (co Cone) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	frame := newLocalFrame(co.BaseCenter, co.Apex)
+	local := frame.toLocal(point)
+	return (math.Atan2(local.Y, local.X) + math.Pi) / (2 * math.Pi), saturate(local.Z / frame.height)
+}
+
+func (co Cone) description() string {
+	return fmt.Sprintf(
+		"%s - Base Center: %v, Apex: %v, Radius: %f, Capped: %t, Material: %s",
+		reflect.TypeOf(co),
+		co.BaseCenter,
+		co.Apex,
+		co.Radius,
+		co.Capped,
+		reflect.TypeOf(co.Mat),
+	)
+}
+
+func (to Torus) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	sh, ok := closestSurfaceHit(to.surfaceHits(r), tMin, tMax)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+	return hitRecord{
+		t:        sh.t,
+		p:        r.PointAtT(sh.t),
+		normal:   sh.normal,
+		shape:    &to,
+		material: to.Mat,
+	}
+}
+
+func (to Torus) surfaceHits(r *ray) []surfaceHit {
+	frame := newLocalFrame(to.Center, r3.Add(to.Center, to.Axis))
+	o, d := frame.toLocal(r.p), frame.toLocalDirection(r.normalizedDirection)
+
+	// all hits are within the bounding sphere of the torus, so start solving from where the ray enters it
+	// to keep the quartic well conditioned when the ray starts far away
+	tStart := -r3.Dot(o, d) - (to.MajorRadius + to.MinorRadius)
+	o = r3.Add(o, r3.Scale(tStart, d))
+
+	// (x^2 + y^2 + z^2 + R^2 - r^2)^2 = 4R^2(x^2 + y^2)
+	majorSqrd := to.MajorRadius * to.MajorRadius
+	f := r3.Dot(o, d)
+	e := r3.Dot(o, o) + majorSqrd - to.MinorRadius*to.MinorRadius
+	roots := solveQuartic([5]float64{
+		e*e - 4*majorSqrd*(o.X*o.X+o.Y*o.Y),
+		4*f*e - 8*majorSqrd*(o.X*d.X+o.Y*d.Y),
+		4*f*f + 2*e - 4*majorSqrd*(d.X*d.X+d.Y*d.Y),
+		4 * f,
+		1,
+	})
+
+	hits := make([]surfaceHit, 0, len(roots))
+	for _, t := range roots {
+		p := r3.Add(o, r3.Scale(t, d))
+		ringCenter := r3.Scale(to.MajorRadius, r3.Unit(r3.Vec{X: p.X, Y: p.Y}))
+		hits = append(hits, surfaceHit{t: t + tStart, normal: frame.toWorldDirection(r3.Sub(p, ringCenter))})
+	}
+	return hits
+}
+
+func (to *Torus) Translate(tv r3.Vec) {
+	to.Center = r3.Add(tv, to.Center)
+}
+
+func (to *Torus) Scale(c float64) {
+	to.MajorRadius *= c
+	to.MinorRadius *= c
+}
+
+func (to *Torus) Rotate(rv r3.Vec) {
+	to.Center = rotatePoint(to.Center, rv)
+	to.Axis = rotatePoint(to.Axis, rv)
+}
+
+func (to Torus) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	frame := newLocalFrame(to.Center, r3.Add(to.Center, to.Axis))
+	extent := to.MajorRadius + to.MinorRadius
+	return frame.bounds(r3.Vec{X: -extent, Y: -extent, Z: -to.MinorRadius}, r3.Vec{X: extent, Y: extent, Z: to.MinorRadius})
+}
+
+func (to Torus) centroid() r3.Vec {
+	return to.Center
+}
+
+// u goes around the ring, v goes around the tube
+func (to Torus) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	frame := newLocalFrame(to.Center, r3.Add(to.Center, to.Axis))
+	local := frame.toLocal(point)
+	distanceFromAxis := math.Sqrt(local.X*local.X + local.Y*local.Y)
+	return (math.Atan2(local.Y, local.X) + math.Pi) / (2 * math.Pi), (math.Atan2(local.Z, distanceFromAxis-to.MajorRadius) + math.Pi) / (2 * math.Pi)
+}
+
+func (to Torus) description() string {
+	return fmt.Sprintf(
+		"%s - Center: %v, Axis: %v, Major Radius: %f, Minor Radius: %f, Material: %s",
+		reflect.TypeOf(to),
+		to.Center,
+		to.Axis,
+		to.MajorRadius,
+		to.MinorRadius,
+		reflect.TypeOf(to.Mat),
+	)
+}
+
+// coordinate system with the z axis going from origin towards target
+type localFrame struct {
+	origin  r3.Vec
+	u, v, w r3.Vec
+	height  float64
+}
+
+func newLocalFrame(origin r3.Vec, target r3.Vec) localFrame {
+	axis := r3.Sub(target, origin)
+	height := r3.Norm(axis)
+	w := r3.Scale(1/height, axis)
+	u, v := orthonormalBasis(w)
+	return localFrame{origin: origin, u: u, v: v, w: w, height: height}
+}
+
+func (f localFrame) toLocal(p r3.Vec) r3.Vec {
+	return f.toLocalDirection(r3.Sub(p, f.origin))
+}
+
+func (f localFrame) toLocalDirection(d r3.Vec) r3.Vec {
+	return r3.Vec{X: r3.Dot(d, f.u), Y: r3.Dot(d, f.v), Z: r3.Dot(d, f.w)}
+}
+
+// returned direction is normalized
+func (f localFrame) toWorldDirection(d r3.Vec) r3.Vec {
+	return r3.Unit(r3.Add(r3.Add(r3.Scale(d.X, f.u), r3.Scale(d.Y, f.v)), r3.Scale(d.Z, f.w)))
+}
+
+// world space bounds of a box in local space
+func (f localFrame) bounds(localMin r3.Vec, localMax r3.Vec) (lowest r3.Vec, highest r3.Vec) {
+	m := Matrix4{
+		{f.u.X, f.v.X, f.w.X, f.origin.X},
+		{f.u.Y, f.v.Y, f.w.Y, f.origin.Y},
+		{f.u.Z, f.v.Z, f.w.Z, f.origin.Z},
+		{0, 0, 0, 1},
+	}
+	return transformBounds(&m, localMin, localMax)
+}
+
+// two unit vectors perpendicular to w and each other
+func orthonormalBasis(w r3.Vec) (u, v r3.Vec) {
+	helper := r3.Vec{X: 1}
+	if math.Abs(w.X) > 0.9 {
+		helper = r3.Vec{Y: 1}
+	}
+	u = r3.Unit(r3.Cross(helper, w))
+	v = r3.Cross(w, u)
+	return u, v
+}
+
+// hit against a circular cap at height z of a shape in a local frame
+func appendCapHit(hits []surfaceHit, o r3.Vec, d r3.Vec, z float64, radius float64, normal r3.Vec) []surfaceHit {
+	if math.Abs(d.Z) < 1e-12 {
+		return hits
+	}
+	t := (z - o.Z) / d.Z
+	x := o.X + t*d.X
+	y := o.Y + t*d.Y
+	if x*x+y*y > radius*radius {
+		return hits
+	}
+	return append(hits, surfaceHit{t: t, normal: normal})
+}
+
+func sortSurfaceHits(hits []surfaceHit) []surfaceHit {
+	// at most four hits, insertion sort is plenty
+	for i := 1; i < len(hits); i++ {
+		for j := i; j > 0 && hits[j].t < hits[j-1].t; j-- {
+			hits[j], hits[j-1] = hits[j-1], hits[j]
+		}
+	}
+	return hits
+}
+
+func closestSurfaceHit(hits []surfaceHit, tMin float64, tMax float64) (surfaceHit, bool) {
+	for _, h := range hits {
+		if h.t > tMin && h.t <= tMax {
+			return h, true
+		}
+	}
+	return surfaceHit{}, false
+}
+
+// ray box intersection with the slab method, returns the distances at which the ray enters and exits the box
+func intersectSlabs(o r3.Vec, d r3.Vec, pMin r3.Vec, pMax r3.Vec) (tNear float64, tFar float64, ok bool) {
+	tNear = math.Inf(-1)
+	tFar = math.Inf(1)
+	for _, axis := range [][4]float64{
+		{o.X, d.X, pMin.X, pMax.X},
+		{o.Y, d.Y, pMin.Y, pMax.Y},
+		{o.Z, d.Z, pMin.Z, pMax.Z},
+	} {
+		origin, direction, low, high := axis[0], axis[1], axis[2], axis[3]
+		if math.Abs(direction) < 1e-12 {
+			if origin < low || origin > high {
+				return 0, 0, false
+			}
+			continue
+		}
+		t1 := (low - origin) / direction
+		t2 := (high - origin) / direction
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tNear = math.Max(tNear, t1)
+		tFar = math.Min(tFar, t2)
+		if tNear > tFar {
+			return 0, 0, false
+		}
+	}
+	return tNear, tFar, true
+}
+
+// outward normal of the face of the box closest to the point
+func boxNormal(p r3.Vec, pMin r3.Vec, pMax r3.Vec) r3.Vec {
+	center := r3.Scale(0.5, r3.Add(pMin, pMax))
+	halfSize := r3.Scale(0.5, r3.Sub(pMax, pMin))
+	local := r3.Sub(p, center)
+	x := local.X / halfSize.X
+	y := local.Y / halfSize.Y
+	z := local.Z / halfSize.Z
+	if math.Abs(x) >= math.Abs(y) && math.Abs(x) >= math.Abs(z) {
+		return r3.Vec{X: math.Copysign(1, x)}
+	} else if math.Abs(y) >= math.Abs(z) {
+		return r3.Vec{Y: math.Copysign(1, y)}
+	}
+	return r3.Vec{Z: math.Copysign(1, z)}
+}
+
+// each face of the box gets the whole texture, projected along the axis the face is looking down
+func boxTextureMap(p r3.Vec, normal r3.Vec, pMin r3.Vec, pMax r3.Vec) (u, v float64) {
+	size := r3.Sub(pMax, pMin)
+	local := r3.Sub(p, pMin)
+	if math.Abs(normal.X) >= math.Abs(normal.Y) && math.Abs(normal.X) >= math.Abs(normal.Z) {
+		return saturate(local.Z / size.Z), saturate(local.Y / size.Y)
+	} else if math.Abs(normal.Y) >= math.Abs(normal.Z) {
+		return saturate(local.X / size.X), saturate(local.Z / size.Z)
+	}
+	return saturate(local.X / size.X), saturate(local.Y / size.Y)
+}
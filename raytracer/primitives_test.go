@@ -0,0 +1,88 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestPrimitivesHitDistanceAndNormal(t *testing.T) {
+	down := ray{p: r3.Vec{X: 0, Y: 10, Z: 0}, normalizedDirection: r3.Vec{X: 0, Y: -1, Z: 0}}
+	sideways := ray{p: r3.Vec{X: -10, Y: 0, Z: 0}, normalizedDirection: r3.Vec{X: 1, Y: 0, Z: 0}}
+	up := r3.Vec{Y: 1}
+	tests := []struct {
+		name           string
+		shape          Shape
+		r              ray
+		expectedT      float64
+		expectedNormal r3.Vec
+	}{
+		{"plane", &Plane{Point: r3.Vec{Y: 1}, Normal: up}, down, 9, up},
+		{"disk", &Disk{Center: r3.Vec{Y: 2}, Normal: up, Radius: 1}, down, 8, up},
+		{"box", &Box{Min: r3.Vec{X: -1, Y: -1, Z: -1}, Max: r3.Vec{X: 1, Y: 3, Z: 1}}, down, 7, up},
+		{"oriented box", &OrientedBox{HalfSize: r3.Vec{X: 1, Y: 1, Z: 1}, Orientation: QuaternionFromAxisAngle(up, 45)}, sideways, 10 - math.Sqrt2, r3.Unit(r3.Vec{X: -1, Z: 1})},
+		{"capped cylinder top", &Cylinder{BaseCenter: r3.Vec{}, TopCenter: r3.Vec{Y: 4}, Radius: 1, Capped: true}, down, 6, up},
+		{"cylinder side", &Cylinder{BaseCenter: r3.Vec{Y: -2}, TopCenter: r3.Vec{Y: 2}, Radius: 1}, sideways, 9, r3.Vec{X: -1}},
+		{"cone apex", &Cone{BaseCenter: r3.Vec{}, Apex: r3.Vec{Y: 2}, Radius: 1, Capped: true}, down, 8, up},
+		{"cone side", &Cone{BaseCenter: r3.Vec{Y: -1}, Apex: r3.Vec{Y: 1}, Radius: 2}, sideways, 9, r3.Unit(r3.Vec{X: -1, Y: 1})},
+		{"torus", &Torus{Center: r3.Vec{}, Axis: up, MajorRadius: 3, MinorRadius: 1}, sideways, 6, r3.Vec{X: -1}},
+		{"torus hole", &Torus{Center: r3.Vec{}, Axis: r3.Vec{X: 1}, MajorRadius: 3, MinorRadius: 1}, sideways, -1, r3.Vec{}},
+	}
+
+	for _, test := range tests {
+		hr := test.shape.hit(&test.r, 0, math.MaxFloat64)
+		if test.expectedT < 0 {
+			if hr.t > 0 {
+				t.Errorf("%s: expected a miss, but hit at t=%f", test.name, hr.t)
+			}
+			continue
+		}
+		if math.Abs(hr.t-test.expectedT) > 1e-6 {
+			t.Errorf("%s: expected hit at t=%f, got t=%f", test.name, test.expectedT, hr.t)
+		}
+		if r3.Norm(r3.Sub(hr.normal, test.expectedNormal)) > 1e-6 {
+			t.Errorf("%s: expected normal %v, got %v", test.name, test.expectedNormal, hr.normal)
+		}
+		lowest, highest := test.shape.computeSquareBounds()
+		p := hr.p
+		if p.X < lowest.X-1e-9 || p.Y < lowest.Y-1e-9 || p.Z < lowest.Z-1e-9 || p.X > highest.X+1e-9 || p.Y > highest.Y+1e-9 || p.Z > highest.Z+1e-9 {
+			t.Errorf("%s: hit point %v is outside of the bounds %v %v", test.name, p, lowest, highest)
+		}
+		if u, v := test.shape.textureMap(hr.p, hr.normal); u < 0 || u > 1 || v < 0 || v > 1 {
+			t.Errorf("%s: texture coordinates (%f, %f) are outside of [0, 1]", test.name, u, v)
+		}
+	}
+}
+
+func TestBoxRotate(t *testing.T) {
+	// a rotated box is hit like an oriented box rotated the same way, also inside of a CSG
+	box := &Box{Min: r3.Vec{X: 1, Y: -1, Z: -0.5}, Max: r3.Vec{X: 3, Y: 1, Z: 0.5}}
+	oriented := &OrientedBox{Center: r3.Vec{X: 2}, HalfSize: r3.Vec{X: 1, Y: 1, Z: 0.5}}
+	csg := &CSG{Operation: Union, Left: &Box{Min: box.Min, Max: box.Max}, Right: &Sphere{Center: r3.Vec{Y: 10}, Radius: 1}}
+	rv := r3.Vec{Y: 30, Z: 20}
+	box.Rotate(rv)
+	oriented.Rotate(rv)
+	csg.Rotate(rv)
+	lowest, highest := box.computeSquareBounds()
+	expectedLowest, expectedHighest := oriented.computeSquareBounds()
+	if r3.Norm(r3.Sub(lowest, expectedLowest)) > 1e-9 || r3.Norm(r3.Sub(highest, expectedHighest)) > 1e-9 {
+		t.Errorf("expected the bounds of the rotated box to be %v %v, got %v %v", expectedLowest, expectedHighest, lowest, highest)
+	}
+	hits := 0
+	for _, x := range []float64{0, 1, 1.5, 2, 2.5, 3} {
+		r := ray{p: r3.Vec{X: x, Y: 0.2, Z: -10}, normalizedDirection: r3.Vec{Z: 1}}
+		expected := oriented.hit(&r, 0, math.MaxFloat64)
+		if expected.t > 0 {
+			hits++
+		}
+		for name, shape := range map[string]Shape{"box": box, "CSG": csg} {
+			hr := shape.hit(&r, 0, math.MaxFloat64)
+			if math.Abs(hr.t-expected.t) > 1e-9 || (expected.t > 0 && r3.Norm(r3.Sub(hr.normal, expected.normal)) > 1e-9) {
+				t.Errorf("%s: expected the rotated box to be hit at t=%f with normal %v at x=%f, got t=%f with normal %v", name, expected.t, expected.normal, x, hr.t, hr.normal)
+			}
+		}
+	}
+	if hits < 3 {
+		t.Errorf("expected most rays to hit the rotated box, %d did", hits)
+	}
+}
@@ -0,0 +1,92 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"sync"
+)
+
+// render that refines over passes, every pass adds AntiAliasingFactor samples to every pixel
+// eg to show a noisy preview within seconds and let it converge while it is on screen
+type ProgressiveRender struct {
+	imageSpec ImageSpec
+	scene     Scene
+	camera    camera
+	bvh       *boundingVolumeHierarchy
+	fb        *Framebuffer
+	passes    int
+	tiles     int // of the pass being rendered
+	tilesDone int
+	lock      sync.Mutex // held while tiles are added to the framebuffer and while snapshots are taken
+}
+
+func NewProgressiveRender(imageSpec ImageSpec, scene Scene) *ProgressiveRender {
+	shapes := scene.renderShapes()
+	return &ProgressiveRender{
+		imageSpec: imageSpec,
+		scene:     scene,
+		camera:    scene.camera(float64(imageSpec.Width) / float64(imageSpec.Height)),
+		bvh:       NewBoundingVolumeHierarchy(&shapes),
+		fb:        NewFramebuffer(imageSpec.Width, imageSpec.Height),
+	}
+}
+
+// renders one more pass and returns the image so far, passes can't be rendered at the same time
+func (p *ProgressiveRender) RenderPass() *image.RGBA {
+	tiles := p.imageSpec.tiles()
+	p.lock.Lock()
+	p.tiles, p.tilesDone = len(tiles), 0
+	p.lock.Unlock()
+	renderPass(p.imageSpec, p.scene, p.camera, p.bvh, p.fb, &p.lock, tiles, func() {
+		p.lock.Lock()
+		p.tilesDone++
+		p.lock.Unlock()
+	})
+	p.lock.Lock()
+	p.passes++
+	p.lock.Unlock()
+	return p.Snapshot()
+}
+
+// renders passes one after another, sending the image after each one
+func (p *ProgressiveRender) RenderPasses(passes int, snapshots chan<- *image.RGBA) {
+	for i := 0; i < passes; i++ {
+		snapshots <- p.RenderPass()
+	}
+}
+
+// image so far, can be taken while a pass is rendering, the tiles the pass has finished then have more samples
+func (p *ProgressiveRender) Snapshot() *image.RGBA {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.imageSpec.output(p.fb)
+}
+
+// copy of the linear colors and samples taken so far
+func (p *ProgressiveRender) Framebuffer() *Framebuffer {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fb := Framebuffer{
+		Width:   p.fb.Width,
+		Height:  p.fb.Height,
+		Pixels:  append([]r3.Vec(nil), p.fb.Pixels...),
+		Samples: append([]int(nil), p.fb.Samples...),
+	}
+	return &fb
+}
+
+func (p *ProgressiveRender) Passes() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.passes
+}
+
+// fraction of the tiles of the pass being rendered that are done, 1 once a pass is done
+func (p *ProgressiveRender) Progress() float64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.tiles == 0 {
+		return 0
+	}
+	return float64(p.tilesDone) / float64(p.tiles)
+}
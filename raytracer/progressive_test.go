@@ -0,0 +1,37 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"testing"
+)
+
+func TestProgressiveRenderConverges(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	imageSpec.AntiAliasingFactor = 2
+	imageSpec.Sampler = Sobol
+
+	var fb, expected *Framebuffer
+	snapshots := make(chan *image.RGBA, 4)
+	withoutStdout(t, func() {
+		progressive := NewProgressiveRender(imageSpec, scene)
+		progressive.RenderPasses(4, snapshots)
+		fb = progressive.Framebuffer()
+
+		// the passes take the same samples as a single render with all of them
+		imageSpec.AntiAliasingFactor = 8
+		expected = RenderFramebuffer(imageSpec, scene)
+	})
+	if len(snapshots) != 4 {
+		t.Errorf("expected a snapshot after each of the 4 passes, got %d", len(snapshots))
+	}
+	for i := range fb.Pixels {
+		if fb.Samples[i] != 8 {
+			t.Fatalf("expected 8 samples after 4 passes of 2, got %d", fb.Samples[i])
+		}
+		if r3.Norm(r3.Sub(fb.Pixels[i], expected.Pixels[i])) > 1e-9 {
+			t.Fatalf("expected pixel %d to be %v like a single render, got %v", i, expected.Pixels[i], fb.Pixels[i])
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+)
+
+type Projection int
+
+const (
+	Perspective     = iota
+	Orthographic    // parallel rays, eg for technical drawings and isometric views
+	Fisheye         // equidistant, CameraFov is the angle across the height of the image
+	Equirectangular // 360 by 180 degree panorama, use an image twice as wide as it is high
+	Cubemap         // the 6 faces of a cube around the camera in a 3 by 2 grid, use an image 1.5 times as wide as it is high
+)
+
+// parallel rays from a rectangle of height world units around the look from point
+type orthographicCamera struct {
+	cameraFrame
+	width  float64
+	height float64
+}
+
+type fisheyeCamera struct {
+	cameraFrame
+	aspect float64
+	fov    float64 // in radians
+}
+
+type equirectangularCamera struct {
+	cameraFrame
+}
+
+// faces are laid out as right, left, up on the top row and down, forwards, backwards on the bottom row
+type cubemapCamera struct {
+	cameraFrame
+}
+
+// forwards, right and up of each face of the cubemap in the camera frame
+var cubemapFaces = [6][3]r3.Vec{
+	{{X: 1}, {Z: -1}, {Y: 1}},
+	{{X: -1}, {Z: 1}, {Y: 1}},
+	{{Y: 1}, {X: 1}, {Z: -1}},
+	{{Y: -1}, {X: 1}, {Z: 1}},
+	{{Z: 1}, {X: 1}, {Y: 1}},
+	{{Z: -1}, {X: -1}, {Y: 1}},
+}
+
+// builds the camera for the projection of the scene
+func (s Scene) camera(aspect float64) camera {
+	fov, aperature := s.lens(aspect)
+	switch s.CameraProjection {
+	case Perspective:
+		cam := NewCamera(s.CameraLookFrom, s.CameraLookAt, s.CameraUp, fov, aspect, aperature, s.focusDistance())
+		cam.setAperature(s.CameraAperatureShape)
+		return &cam
+	case Orthographic:
+		height := s.CameraOrthographicHeight
+		if height <= 0 {
+			// frame the focus plane the same as the perspective camera would
+			height = 2 * math.Tan(fov*math.Pi/360) * s.focusDistance()
+		}
+		return &orthographicCamera{
+			cameraFrame: newCameraFrame(s.CameraLookFrom, s.CameraLookAt, s.CameraUp),
+			width:       aspect * height,
+			height:      height,
+		}
+	case Fisheye:
+		return &fisheyeCamera{
+			cameraFrame: newCameraFrame(s.CameraLookFrom, s.CameraLookAt, s.CameraUp),
+			aspect:      aspect,
+			fov:         fov * math.Pi / 180,
+		}
+	case Equirectangular:
+		return &equirectangularCamera{cameraFrame: newCameraFrame(s.CameraLookFrom, s.CameraLookAt, s.CameraUp)}
+	case Cubemap:
+		return &cubemapCamera{cameraFrame: newCameraFrame(s.CameraLookFrom, s.CameraLookAt, s.CameraUp)}
+	default:
+		panic(fmt.Sprintf("No camera projection found for %d", s.CameraProjection))
+	}
+}
+
+// direction in the camera frame, x to the right, y up and z forwards
+func (f cameraFrame) direction(x float64, y float64, z float64) r3.Vec {
+	return r3.Unit(r3.Add(r3.Add(r3.Scale(x, f.u), r3.Scale(y, f.v)), r3.Scale(-z, f.w)))
+}
+
+func (c orthographicCamera) getRay(s float64, t float64, samples *pixelSamples) ray {
+	offset := r3.Add(r3.Scale((s-0.5)*c.width, c.u), r3.Scale((t-0.5)*c.height, c.v))
+	return ray{
+		p:                   r3.Add(c.origin, offset),
+		normalizedDirection: r3.Scale(-1, c.w),
+		time:                c.rayTime(samples),
+		samples:             samples,
+	}
+}
+
+func (c fisheyeCamera) getRay(s float64, t float64, samples *pixelSamples) ray {
+	x := (2*s - 1) * c.aspect
+	y := 2*t - 1
+	r := math.Sqrt(x*x + y*y)
+	// the angle from the view direction grows evenly with the distance from the center of the image
+	theta := math.Min(math.Pi, r*c.fov/2)
+	phi := math.Atan2(y, x)
+	sinTheta := math.Sin(theta)
+	return ray{
+		p:                   c.origin,
+		normalizedDirection: c.direction(sinTheta*math.Cos(phi), sinTheta*math.Sin(phi), math.Cos(theta)),
+		time:                c.rayTime(samples),
+		samples:             samples,
+	}
+}
+
+func (c equirectangularCamera) getRay(s float64, t float64, samples *pixelSamples) ray {
+	longitude := (s - 0.5) * 2 * math.Pi
+	latitude := (t - 0.5) * math.Pi
+	return ray{
+		p:                   c.origin,
+		normalizedDirection: c.direction(math.Cos(latitude)*math.Sin(longitude), math.Sin(latitude), math.Cos(latitude)*math.Cos(longitude)),
+		time:                c.rayTime(samples),
+		samples:             samples,
+	}
+}
+
+func (c cubemapCamera) getRay(s float64, t float64, samples *pixelSamples) ray {
+	column := math.Min(2, math.Floor(s*3))
+	row := math.Min(1, math.Floor(t*2)) // 1 is the top row
+	face := int(column) + 3*int(1-row)
+	// position on the face between [-1, 1]
+	x := (s*3-column)*2 - 1
+	y := (t*2-row)*2 - 1
+
+	d := r3.Add(cubemapFaces[face][0], r3.Add(r3.Scale(x, cubemapFaces[face][1]), r3.Scale(y, cubemapFaces[face][2])))
+	return ray{
+		p:                   c.origin,
+		normalizedDirection: c.direction(d.X, d.Y, d.Z),
+		time:                c.rayTime(samples),
+		samples:             samples,
+	}
+}
@@ -0,0 +1,53 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestCameraProjections(t *testing.T) {
+	// looking down the z axis, with x to the left of the image
+	scene := Scene{
+		CameraLookFrom: r3.Vec{},
+		CameraLookAt:   r3.Vec{Z: 1},
+		CameraUp:       r3.Vec{Y: 1},
+		CameraFov:      90,
+	}
+	forward, right, up := r3.Vec{Z: 1}, r3.Vec{X: -1}, r3.Vec{Y: 1}
+	diagonal := r3.Unit(r3.Add(forward, right))
+
+	tests := []struct {
+		name              string
+		projection        Projection
+		aspect            float64
+		s, t              float64
+		expectedOrigin    r3.Vec
+		expectedDirection r3.Vec
+	}{
+		{"orthographic center", Orthographic, 2, 0.5, 0.5, r3.Vec{}, forward},
+		{"orthographic corner", Orthographic, 2, 1, 1, r3.Vec{X: -4, Y: 2}, forward},
+		{"fisheye center", Fisheye, 1, 0.5, 0.5, r3.Vec{}, forward},
+		{"fisheye edge", Fisheye, 1, 1, 0.5, r3.Vec{}, diagonal},
+		{"equirectangular center", Equirectangular, 2, 0.5, 0.5, r3.Vec{}, forward},
+		{"equirectangular right", Equirectangular, 2, 0.75, 0.5, r3.Vec{}, right},
+		{"equirectangular behind", Equirectangular, 2, 1, 0.5, r3.Vec{}, r3.Scale(-1, forward)},
+		{"equirectangular up", Equirectangular, 2, 0.3, 1, r3.Vec{}, up},
+		{"cubemap right face", Cubemap, 1.5, 1.0 / 6, 0.75, r3.Vec{}, right},
+		{"cubemap up face", Cubemap, 1.5, 5.0 / 6, 0.75, r3.Vec{}, up},
+		{"cubemap forward face", Cubemap, 1.5, 0.5, 0.25, r3.Vec{}, forward},
+		{"cubemap forward face left edge", Cubemap, 1.5, 1.0 / 3, 0.25, r3.Vec{}, r3.Unit(r3.Sub(forward, right))},
+		{"cubemap backward face left edge", Cubemap, 1.5, 2.0 / 3, 0.25, r3.Vec{}, r3.Unit(r3.Sub(right, forward))},
+	}
+	for _, test := range tests {
+		scene.CameraProjection = test.projection
+		scene.CameraOrthographicHeight = 4
+		r := scene.camera(test.aspect).getRay(test.s, test.t, nil)
+		if r3.Norm(r3.Sub(r.p, test.expectedOrigin)) > 1e-9 || r3.Norm(r3.Sub(r.normalizedDirection, test.expectedDirection)) > 1e-9 {
+			t.Errorf("%s: expected ray from %v towards %v, got from %v towards %v", test.name, test.expectedOrigin, test.expectedDirection, r.p, r.normalizedDirection)
+		}
+		if math.Abs(r3.Norm(r.normalizedDirection)-1) > 1e-9 {
+			t.Errorf("%s: expected a normalized direction, got %v", test.name, r.normalizedDirection)
+		}
+	}
+}
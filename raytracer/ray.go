@@ -7,6 +7,8 @@ import (
 type ray struct {
 	p                   r3.Vec
 	normalizedDirection r3.Vec
+	time                float64       // when the ray was cast, between the shutter opening and closing, for motion blur
+	samples             *pixelSamples // of the pixel the ray was cast for, passed on to the rays that follow from it
 }
 
 func (r ray) PointAtT(t float64) r3.Vec {
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"gonum.org/v1/gonum/spatial/r3"
 	"image"
-	"math"
-	"math/rand"
+	"sync"
 	"time"
 )
 
 const bvhCentroidJitterFactor = 0.0000000001
+const bvhCentroidJitterSeed = 1
 const softShadowMonteCarloMaxLengthDeviation = 0.25
 const backgroundColorFracR = 0.0
 const backgroundColorFracG = 0.0
@@ -30,106 +30,224 @@ type ImageSpec struct {
 	SoftShadowMonteCarloRepetitions int
 	WorkerCount                     int
 	BvhTraversalAlgorithm           BoundingVolumeHierarchyTraversalAlgorithm
+	TileSize                        int // width and height of the tiles workers render, defaults to 32
+	TileOrder                       TileOrder
+	Seed                            int64 // renders with the same seed are identical
+	Sampler                         SamplerType
+	AdaptiveSampling                *AdaptiveSampling // AntiAliasingFactor is then the least samples a pixel gets
+	Crop                            image.Rectangle   // only these pixels are traced, from the top left of the image, empty traces every pixel
+	CropOutput                      CropOutput
 }
 
 type Scene struct {
 	CameraLookFrom   r3.Vec
 	CameraLookAt     r3.Vec
 	CameraUp         r3.Vec
-	CameraFocusPoint r3.Vec // when using camera aperature, point to focus on
+	CameraFocusPoint *r3.Vec // when using camera aperature, point to focus on, nil focuses on the look at point
 
-	CameraAperature float64
-	CameraFov       float64 // in degrees
+	CameraAperature      float64
+	CameraAperatureShape Aperature // shape of the out of focus highlights, defaults to a circle
+	CameraFocusDistance  float64   // distance from the camera to focus at, used instead of CameraFocusPoint when set
+	CameraFov            float64   // in degrees
+
+	PhysicalCamera *PhysicalCamera // optional, sets the field of view, aperature, shutter and exposure from real camera settings
+
+	CameraProjection         Projection
+	CameraOrthographicHeight float64 // height of the view in world units, defaults to the height of the focus plane at CameraFov
+
+	// times the shutter opens and closes for motion blur, see MovingShape, both 0 renders a single instant
+	CameraShutterOpen  float64
+	CameraShutterClose float64
 
 	Shapes []Shape
+	Root   *SceneNode // optional scene graph, flattened and rendered alongside Shapes
 	Lights []Light
+
+	Atmosphere *Atmosphere // optional medium filling the whole scene
 }
 
-type raytraceJob struct {
-	i int
-	j int
+func GenerateImage(imageSpec ImageSpec, scene Scene) *image.RGBA {
+	return imageSpec.output(RenderFramebuffer(imageSpec, scene))
 }
 
-type raytraceResult struct {
-	pixelIdx       int
-	pixelColorFrac r3.Vec
+// linear colors and samples taken of every pixel, eg to see where adaptive sampling spent its samples
+func RenderFramebuffer(imageSpec ImageSpec, scene Scene) *Framebuffer {
+	shapes := scene.renderShapes()
+	bvh := NewBoundingVolumeHierarchy(&shapes)
+	return renderFramebuffer(imageSpec, scene, scene.camera(float64(imageSpec.Width)/float64(imageSpec.Height)), bvh)
 }
 
-func GenerateImage(imageSpec ImageSpec, scene Scene) *image.RGBA {
-	lookFromMinusLookAt := r3.Sub(scene.CameraLookFrom, scene.CameraLookAt)
-	cam := NewCamera(
-		scene.CameraLookFrom,
-		scene.CameraLookAt,
-		scene.CameraUp,
-		scene.CameraFov,
-		float64(imageSpec.Width)/float64(imageSpec.Height),
-		scene.CameraAperature,
-		math.Sqrt(lookFromMinusLookAt.X*lookFromMinusLookAt.X+lookFromMinusLookAt.Y*lookFromMinusLookAt.Y+lookFromMinusLookAt.Z*lookFromMinusLookAt.Z),
-	)
-	bvh := NewBoundingVolumeHierarchy(&scene.Shapes)
-	myImage := image.NewRGBA(image.Rect(0, 0, imageSpec.Width, imageSpec.Height))
-	jobs := make(chan raytraceJob, imageSpec.Height*imageSpec.Width)
-	results := make(chan raytraceResult, imageSpec.Height*imageSpec.Width)
+// renders the scene with an already built bounding volume hierarchy, eg reused between frames of an animation
+func traceImage(imageSpec ImageSpec, scene Scene, bvh *boundingVolumeHierarchy) *image.RGBA {
+	return traceCameraImage(imageSpec, scene, scene.camera(float64(imageSpec.Width)/float64(imageSpec.Height)), bvh)
+}
+
+// renders the scene as seen from cam instead of the camera of the scene, eg for each eye of a stereo pair
+func traceCameraImage(imageSpec ImageSpec, scene Scene, cam camera, bvh *boundingVolumeHierarchy) *image.RGBA {
+	return imageSpec.output(renderFramebuffer(imageSpec, scene, cam, bvh))
+}
+
+// workers render whole tiles of the image straight into the framebuffer, each pixel is only written by one worker
+func renderFramebuffer(imageSpec ImageSpec, scene Scene, cam camera, bvh *boundingVolumeHierarchy) *Framebuffer {
+	fb := NewFramebuffer(imageSpec.Width, imageSpec.Height)
+	renderPass(imageSpec, scene, cam, bvh, fb, &sync.Mutex{}, imageSpec.tiles(), nil)
+	return fb
+}
+
+// adds AntiAliasingFactor more samples to every pixel of the tiles, continuing from the samples they already have
+// finished tiles are added while holding the lock, so the framebuffer can be read while the pass renders
+// tileDone, when set, is called after each tile is added
+// a panic in a worker is raised again here, so callers can recover from it, eg the render service failing a job
+func renderPass(imageSpec ImageSpec, scene Scene, cam camera, bvh *boundingVolumeHierarchy, fb *Framebuffer, lock sync.Locker, tiles []tile, tileDone func()) {
+	cam.setShutter(scene.shutter())
+	exposure := scene.exposure()
+	jobs := make(chan tile, len(tiles))
+	done := make(chan tile, len(tiles))
 	workers := imageSpec.WorkerCount
+	failed := make(chan interface{}, workers)
 	for i := 0; i < workers; i++ {
-		go computePixel(i, &imageSpec, &cam, bvh, &scene.Lights, jobs, results)
+		go func(id int) {
+			defer func() {
+				if r := recover(); r != nil {
+					failed <- r
+				}
+			}()
+			renderTiles(id, &imageSpec, cam, bvh, &scene.Lights, exposure, fb, lock, jobs, done)
+		}(i)
 	}
 
 	startTime := time.Now()
-	for j := imageSpec.Height - 1; j >= 0; j-- {
-		for i := 0; i < imageSpec.Width; i++ {
-			jobs <- raytraceJob{
-				i: i,
-				j: j,
-			}
-		}
+	for _, t := range tiles {
+		jobs <- t
 	}
 	close(jobs)
 
+	total := 0
+	for _, t := range tiles {
+		total += t.pixelCount()
+	}
 	count := 0
-	for j := imageSpec.Height - 1; j >= 0; j-- {
-		for i := 0; i < imageSpec.Width; i++ {
-			result := <-results
-			myImage.Pix[result.pixelIdx+0] = uint8(math.Min(255, result.pixelColorFrac.X*255.99)) // 1st pixel red
-			myImage.Pix[result.pixelIdx+1] = uint8(math.Min(255, result.pixelColorFrac.Y*255.99)) // 1st pixel green
-			myImage.Pix[result.pixelIdx+2] = uint8(math.Min(255, result.pixelColorFrac.Z*255.99)) // 1st pixel blue
-			myImage.Pix[result.pixelIdx+3] = 255                                                  // 1st pixel alpha
-
-			count++
-			if count%1000 == 0 {
-				fmt.Printf("%.2f%% pixels rendered, %s\n", float64(count)/float64(imageSpec.Height*imageSpec.Width)*100.0, time.Since(startTime).String())
-			}
+	for range tiles {
+		var t tile
+		select {
+		case t = <-done:
+		case r := <-failed:
+			// the other workers go on with the tiles left, done is buffered so they never block
+			panic(r)
+		}
+		previousCount := count
+		count += t.pixelCount()
+		if count/1000 > previousCount/1000 {
+			fmt.Printf("%.2f%% pixels rendered, %s\n", float64(count)/float64(total)*100.0, time.Since(startTime).String())
+		}
+		if tileDone != nil {
+			tileDone()
 		}
 	}
 
 	fmt.Printf("Finished ray tracing in %s\n", time.Since(startTime).String())
-	return myImage
 }
 
-func computePixel(id int, is *ImageSpec, camera *camera, bvh *boundingVolumeHierarchy, lights *[]Light, jobs <-chan raytraceJob, results chan<- raytraceResult) {
-	var traceFunction = bvh.getTraceFunction(is.BvhTraversalAlgorithm)
-	for job := range jobs {
-		pixelColor := r3.Vec{}
-		for s := 0; s < is.AntiAliasingFactor; s++ {
-			u := (float64(job.i) + rand.Float64()) / float64(is.Width)
-			v := (float64(job.j) + rand.Float64()) / float64(is.Height)
-			ray := camera.getRay(u, v)
-			pixelColor = r3.Add(pixelColor, color(is, &ray, bvh, traceFunction, lights, 0))
+// distance along the view direction to the plane in focus
+// without a focus distance or point, or with a focus point behind the camera (see validate), focus on the look at point
+func (s Scene) focusDistance() float64 {
+	if s.CameraFocusDistance > 0 {
+		return s.CameraFocusDistance
+	}
+	if s.CameraFocusPoint != nil {
+		if focusDistance := s.focusPointDistance(); focusDistance > 0 {
+			return focusDistance
 		}
-		pixelColor = r3.Scale(1.0/float64(is.AntiAliasingFactor), pixelColor)
-		pixelColor = r3.Vec{
-			X: pixelColor.X,
-			Y: pixelColor.Y,
-			Z: pixelColor.Z,
+	}
+	return r3.Norm(r3.Sub(s.CameraLookFrom, s.CameraLookAt))
+}
+
+// points off the center of the view are in focus on the plane through them facing the camera, not at their distance
+// 0 or less when the point is behind the camera
+func (s Scene) focusPointDistance() float64 {
+	viewDirection := r3.Unit(r3.Sub(s.CameraLookAt, s.CameraLookFrom))
+	return r3.Dot(r3.Sub(*s.CameraFocusPoint, s.CameraLookFrom), viewDirection)
+}
+
+// checks the settings of the scene that would panic or be ignored while rendering, eg before a render service queues it
+func (s Scene) validate() error {
+	if s.CameraProjection < Perspective || s.CameraProjection > Cubemap {
+		return fmt.Errorf("no camera projection found for %d", s.CameraProjection)
+	}
+	if s.CameraFocusDistance <= 0 && s.CameraFocusPoint != nil && s.focusPointDistance() <= 0 {
+		return fmt.Errorf("camera focus point %v is behind the camera", *s.CameraFocusPoint)
+	}
+	// moving shapes and CSGs read from a scene file were not made by NewMovingShape or NewCSG
+	for _, shape := range s.Shapes {
+		if ms, ok := shape.(*MovingShape); ok {
+			if err := ms.Motion.validate(); err != nil {
+				return err
+			}
 		}
-		pixelIdx := (((is.Height - 1 - job.j) * is.Width) + job.i) * 4
+	}
+	for _, shape := range s.renderShapes() {
+		if err := validateCSGs(shape); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// all shapes to render, with the scene graph flattened into world space and the atmosphere as a medium
+func (s Scene) renderShapes() []Shape {
+	shapes := make([]Shape, 0, len(s.Shapes))
+	shapes = append(shapes, s.Shapes...)
+	if s.Root != nil {
+		shapes = append(shapes, s.Root.flatten()...)
+	}
+	if s.Atmosphere != nil {
+		shapes = append(shapes, s.Atmosphere.medium())
+	}
+	return shapes
+}
 
-		// fmt.Printf("Worker %v finished job for (%v, %v)\n", id, job.i, job.j)
-		results <- raytraceResult{
-			pixelIdx:       pixelIdx,
-			pixelColorFrac: pixelColor,
+func renderTiles(id int, is *ImageSpec, camera camera, bvh *boundingVolumeHierarchy, lights *[]Light, exposure float64, fb *Framebuffer, lock sync.Locker, jobs <-chan tile, done chan<- tile) {
+	var traceFunction = bvh.getTraceFunction(is.BvhTraversalAlgorithm)
+	sampler := newSampler(is.Sampler, is.AntiAliasingFactor)
+	for t := range jobs {
+		result := traceTile(is, camera, bvh, traceFunction, lights, exposure, sampler, fb, t)
+		lock.Lock()
+		fb.addTile(t, result)
+		lock.Unlock()
+
+		// fmt.Printf("Worker %v finished tile (%v, %v)\n", id, t.x0, t.y0)
+		done <- t
+	}
+}
+
+// traces the pixels of a tile, continuing from the samples the pixels already have in the framebuffer
+func traceTile(is *ImageSpec, camera camera, bvh *boundingVolumeHierarchy, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), lights *[]Light, exposure float64, sampler Sampler, fb *Framebuffer, t tile) tileResult {
+	result := tileResult{Colors: make([]r3.Vec, 0, t.pixelCount()), Samples: make([]int, 0, t.pixelCount())}
+	for y := t.y0; y < t.y1; y++ {
+		for x := t.x0; x < t.x1; x++ {
+			// rays are cast from the bottom of the image up
+			pixelColor, samples := computePixel(is, camera, bvh, traceFunction, lights, x, is.Height-1-y, newPixelSamples(sampler, is.Seed, x, y), fb.Samples[y*fb.Width+x])
+			result.Colors = append(result.Colors, r3.Scale(exposure, pixelColor))
+			result.Samples = append(result.Samples, samples)
 		}
 	}
+	return result
+}
+
+func computePixel(is *ImageSpec, camera camera, bvh *boundingVolumeHierarchy, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), lights *[]Light, i int, j int, samples *pixelSamples, firstSample int) (r3.Vec, int) {
+	pixelColor := r3.Vec{}
+	stats := pixelStatistics{}
+	for s := firstSample; is.needsSample(&stats); s++ {
+		samples.startSample(s)
+		dx, dy := samples.get2D()
+		u := (float64(i) + dx) / float64(is.Width)
+		v := (float64(j) + dy) / float64(is.Height)
+		ray := camera.getRay(u, v, samples)
+		sampleColor := color(is, &ray, bvh, traceFunction, lights, 0)
+		pixelColor = r3.Add(pixelColor, sampleColor)
+		stats.add(sampleColor)
+	}
+	return r3.Scale(1.0/float64(stats.count), pixelColor), stats.count
 }
 
 func color(
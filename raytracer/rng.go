@@ -0,0 +1,40 @@
+package raytracer
+
+// small random number generator (splitmix64) for the samples of one pixel
+// every pixel gets its own generator seeded from its position, so renders are the same whatever order pixels are
+// rendered in and however many workers there are
+type rng struct {
+	state uint64
+}
+
+func newPixelRng(seed int64, x int, y int) *rng {
+	g := rng{state: uint64(seed)}
+	g.state = g.next() ^ uint64(x)
+	g.state = g.next() ^ uint64(y)
+	g.next()
+	return &g
+}
+
+func (g *rng) next() uint64 {
+	g.state += 0x9e3779b97f4a7c15
+	z := g.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// between [0, 1)
+func (g *rng) Float64() float64 {
+	return float64(g.next()>>11) / (1 << 53)
+}
+
+// mixes a value into a seed, eg to get a different seed for every dimension of a pixel
+func hashSeed(seed uint64, value uint64) uint64 {
+	g := rng{state: seed ^ value*0xd1b54a32d192ed03}
+	return g.next()
+}
+
+// between [0, 1), the same for the same seed and value
+func hashFloat64(seed uint64, value uint64) float64 {
+	return float64(hashSeed(seed, value)>>11) / (1 << 53)
+}
@@ -0,0 +1,54 @@
+package raytracer
+
+import (
+	"bytes"
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"testing"
+)
+
+func TestSeededRenderIsDeterministic(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	imageSpec.Seed = 7
+	// use every source of randomness, the lens, soft shadows, fuzzy reflections and motion blur
+	scene.CameraAperature = 0.2
+	scene.CameraAperatureShape = PolygonalAperature{Blades: 6}
+	scene.CameraShutterClose = 1
+	scene.Shapes[1] = &Sphere{Center: r3.Vec{X: -1.5}, Radius: 1, Mat: Metal{Albedo: r3.Vec{X: 0.8, Y: 0.8, Z: 0.8}, Fuzz: 0.3}}
+	scene.Shapes = append(scene.Shapes, NewMovingShape(
+		&Sphere{Center: r3.Vec{Y: 1.5}, Radius: 0.5, Mat: PhongBlinn{ColorFrac: r3.Vec{Z: 1}}},
+		LinearMotion{Velocity: r3.Vec{X: 1}, End: 1},
+	))
+	imageSpec.SoftShadowMonteCarloRepetitions = 2
+
+	render := func(workerCount int, tileSize int, order TileOrder) *image.RGBA {
+		spec := imageSpec
+		spec.WorkerCount, spec.TileSize, spec.TileOrder = workerCount, tileSize, order
+		var img *image.RGBA
+		withoutStdout(t, func() {
+			img = GenerateImage(spec, scene)
+		})
+		return img
+	}
+
+	expected := render(1, 32, Scanline)
+	for _, test := range []struct {
+		workerCount int
+		tileSize    int
+		order       TileOrder
+	}{
+		{1, 32, Scanline},
+		{4, 8, Hilbert},
+		{3, 5, Spiral},
+	} {
+		if !bytes.Equal(render(test.workerCount, test.tileSize, test.order).Pix, expected.Pix) {
+			t.Errorf("expected %d workers with %d pixel %d ordered tiles to render the same image", test.workerCount, test.tileSize, test.order)
+		}
+	}
+
+	imageSpec.Seed = 8
+	if bytes.Equal(render(1, 32, Scanline).Pix, expected.Pix) {
+		t.Errorf("expected a different seed to render a different image")
+	}
+}
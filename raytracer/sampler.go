@@ -0,0 +1,240 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+type SamplerType int
+
+// pattern of the pixel, lens and light samples of a pixel, well spread out samples converge with fewer samples
+const (
+	UniformRandom = iota // independent random numbers
+	Stratified           // jittered grid of AntiAliasingFactor cells for 2D samples, jittered strata for 1D samples
+	Halton               // halton sequence with a different random offset for every pixel
+	Sobol                // owen scrambled sobol (0, 2) sequence, padded with a shuffled copy for every 2 dimensions
+)
+
+// halton uses a prime base for every dimension, dimensions beyond these are uniformly random
+var haltonPrimes = [...]uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131}
+
+// generates the dimensions of the samples of a pixel, the same index, dimension and seed always give the same value
+// seed is different for every pixel and dimension
+type Sampler interface {
+	// between [0, 1)
+	get1D(index int, dimension int, seed uint64) float64
+	// point in the unit square, for dimension and the dimension after it
+	get2D(index int, dimension int, seed uint64) (x float64, y float64)
+}
+
+type uniformRandomSampler struct{}
+
+type stratifiedSampler struct {
+	samplesPerPixel int
+}
+
+type haltonSampler struct{}
+
+type sobolSampler struct{}
+
+func newSampler(samplerType SamplerType, samplesPerPixel int) Sampler {
+	switch samplerType {
+	case UniformRandom:
+		return uniformRandomSampler{}
+	case Stratified:
+		return stratifiedSampler{samplesPerPixel: int(math.Max(1, float64(samplesPerPixel)))}
+	case Halton:
+		return haltonSampler{}
+	case Sobol:
+		return sobolSampler{}
+	default:
+		panic(fmt.Sprintf("No sampler found for %d", samplerType))
+	}
+}
+
+// samples of the pixel a ray was cast for, every random choice made while tracing a sample takes the next dimensions
+// of the sample, choices that don't gain from being spread out (eg reflect or refract) use plain random numbers
+type pixelSamples struct {
+	sampler   Sampler
+	rng       rng // seeded for every sample, so any sample of the pixel can be traced without tracing the ones before it
+	seed      uint64
+	index     int // of the sample being traced
+	dimension int // next dimension of the sample
+}
+
+func newPixelSamples(sampler Sampler, seed int64, x int, y int) *pixelSamples {
+	return &pixelSamples{sampler: sampler, seed: newPixelRng(seed, x, y).next()}
+}
+
+func (s *pixelSamples) startSample(index int) {
+	s.index = index
+	s.dimension = 0
+	s.rng = rng{state: hashSeed(^s.seed, uint64(index))}
+}
+
+// rays made outside of a render have no samples and fall back to the global random source
+func (s *pixelSamples) get1D() float64 {
+	if s == nil {
+		return rand.Float64()
+	}
+	value := s.sampler.get1D(s.index, s.dimension, hashSeed(s.seed, uint64(s.dimension)))
+	s.dimension++
+	return value
+}
+
+func (s *pixelSamples) get2D() (x float64, y float64) {
+	if s == nil {
+		return rand.Float64(), rand.Float64()
+	}
+	x, y = s.sampler.get2D(s.index, s.dimension, hashSeed(s.seed, uint64(s.dimension)))
+	s.dimension += 2
+	return x, y
+}
+
+func (s *pixelSamples) random() float64 {
+	if s == nil {
+		return rand.Float64()
+	}
+	return s.rng.Float64()
+}
+
+// uniform point inside of the unit sphere
+func (s *pixelSamples) inUnitSphere() r3.Vec {
+	u, v := s.get2D()
+	z := 1 - 2*u
+	radius := math.Cbrt(s.get1D())
+	ring := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * v
+	return r3.Scale(radius, r3.Vec{X: ring * math.Cos(phi), Y: ring * math.Sin(phi), Z: z})
+}
+
+func (u uniformRandomSampler) get1D(index int, dimension int, seed uint64) float64 {
+	return hashFloat64(seed, uint64(index))
+}
+
+func (u uniformRandomSampler) get2D(index int, dimension int, seed uint64) (x float64, y float64) {
+	return hashFloat64(seed, uint64(2*index)), hashFloat64(seed, uint64(2*index+1))
+}
+
+// every round of samplesPerPixel samples is stratified on its own, for when more samples are taken than planned
+func (s stratifiedSampler) get1D(index int, dimension int, seed uint64) float64 {
+	round := uint64(index / s.samplesPerPixel)
+	stratum := permute(uint32(index%s.samplesPerPixel), uint32(s.samplesPerPixel), uint32(hashSeed(seed, round)))
+	return (float64(stratum) + hashFloat64(seed^0x5bd1e995, uint64(index))) / float64(s.samplesPerPixel)
+}
+
+// cells of a grid as close to square as the samples per pixel allow, when they don't fill the grid a random
+// selection of the cells is used
+func (s stratifiedSampler) get2D(index int, dimension int, seed uint64) (x float64, y float64) {
+	columns := int(math.Sqrt(float64(s.samplesPerPixel)))
+	rows := (s.samplesPerPixel + columns - 1) / columns
+	round := uint64(index / s.samplesPerPixel)
+	cell := int(permute(uint32(index%s.samplesPerPixel), uint32(columns*rows), uint32(hashSeed(seed, round))))
+	x = (float64(cell%columns) + hashFloat64(seed^0x5bd1e995, uint64(2*index))) / float64(columns)
+	y = (float64(cell/columns) + hashFloat64(seed^0x5bd1e995, uint64(2*index+1))) / float64(rows)
+	return x, y
+}
+
+func (h haltonSampler) get1D(index int, dimension int, seed uint64) float64 {
+	if dimension >= len(haltonPrimes) {
+		return hashFloat64(seed, uint64(index))
+	}
+	// the random offset (cranley patterson rotation) keeps neighbouring pixels from sharing the same pattern
+	value := radicalInverse(uint64(index), haltonPrimes[dimension]) + hashFloat64(seed, 0)
+	return value - math.Floor(value)
+}
+
+func (h haltonSampler) get2D(index int, dimension int, seed uint64) (x float64, y float64) {
+	return h.get1D(index, dimension, seed), h.get1D(index, dimension+1, hashSeed(seed, 1))
+}
+
+func (s sobolSampler) get1D(index int, dimension int, seed uint64) float64 {
+	shuffled := nestedUniformScramble(uint32(index), uint32(seed))
+	return unitFloat(nestedUniformScramble(bits.Reverse32(shuffled), uint32(seed>>32)))
+}
+
+// the first 2 dimensions of sobol are well spread out in 2D, every pair of dimensions shuffles the order of the
+// samples so the pairs aren't correlated with each other
+func (s sobolSampler) get2D(index int, dimension int, seed uint64) (x float64, y float64) {
+	shuffled := nestedUniformScramble(uint32(index), uint32(seed))
+	x = unitFloat(nestedUniformScramble(bits.Reverse32(shuffled), uint32(seed>>32)))
+	y = unitFloat(nestedUniformScramble(sobolSecondDimension(shuffled), uint32(hashSeed(seed, 1))))
+	return x, y
+}
+
+// digits of index in base reversed behind the point, eg 6 in base 2 is 110 and becomes 0.011
+func radicalInverse(index uint64, base uint64) float64 {
+	inverse := 0.0
+	scale := 1.0 / float64(base)
+	for index > 0 {
+		inverse += float64(index%base) * scale
+		index /= base
+		scale /= float64(base)
+	}
+	return inverse
+}
+
+// the direction numbers of the second dimension of sobol follow pascal's triangle mod 2
+func sobolSecondDimension(index uint32) uint32 {
+	result := uint32(0)
+	direction := uint32(1) << 31
+	for ; index != 0; index >>= 1 {
+		if index&1 == 1 {
+			result ^= direction
+		}
+		direction ^= direction >> 1
+	}
+	return result
+}
+
+// owen scrambling, see Burley, Practical Hash-based Owen Scrambling
+func nestedUniformScramble(x uint32, seed uint32) uint32 {
+	x = bits.Reverse32(x)
+	x += seed
+	x ^= x * 0x6c50b47c
+	x ^= x * 0xb82f1e52
+	x ^= x * 0xc7afe638
+	x ^= x * 0x8d22f6e6
+	return bits.Reverse32(x)
+}
+
+func unitFloat(x uint32) float64 {
+	return float64(x) / (1 << 32)
+}
+
+// i-th element of a random permutation of [0, l) chosen by p, see Kensler, Correlated Multi-Jittered Sampling
+func permute(i uint32, l uint32, p uint32) uint32 {
+	w := l - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	for {
+		i ^= p
+		i *= 0xe170893d
+		i ^= p >> 16
+		i ^= (i & w) >> 4
+		i ^= p >> 8
+		i *= 0x0929eb3f
+		i ^= p >> 23
+		i ^= (i & w) >> 1
+		i *= 1 | p>>27
+		i *= 0x6935fa69
+		i ^= (i & w) >> 11
+		i *= 0x74dcb303
+		i ^= (i & w) >> 2
+		i *= 0x9e501cc3
+		i ^= (i & w) >> 2
+		i *= 0xc860a3df
+		i &= w
+		i ^= i >> 5
+		if i < l {
+			break
+		}
+	}
+	return (i + p) % l
+}
@@ -0,0 +1,76 @@
+package raytracer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPermute(t *testing.T) {
+	for _, l := range []uint32{1, 2, 7, 16, 100} {
+		seen := make([]bool, l)
+		for i := uint32(0); i < l; i++ {
+			p := permute(i, l, 12345)
+			if p >= l || seen[p] {
+				t.Fatalf("expected a permutation of [0, %d), got %d twice or out of range", l, p)
+			}
+			seen[p] = true
+		}
+	}
+}
+
+func TestSamplersAreStratified(t *testing.T) {
+	const n = 16
+	for _, samplerType := range []SamplerType{Stratified, Halton, Sobol} {
+		sampler := newSampler(samplerType, n)
+		for pixel := uint64(0); pixel < 10; pixel++ {
+			seed := hashSeed(pixel, 0)
+			strata := make([]int, n)
+			cells := make([]int, n)
+			for i := 0; i < n; i++ {
+				x := sampler.get1D(i, 0, seed)
+				u, v := sampler.get2D(i, 0, seed)
+				if x < 0 || x >= 1 || u < 0 || u >= 1 || v < 0 || v >= 1 {
+					t.Fatalf("sampler %d: expected samples between [0, 1), got %f and (%f, %f)", samplerType, x, u, v)
+				}
+				strata[int(x*n)]++
+				cells[int(u*4)+4*int(v*4)]++
+			}
+			for i := 0; i < n; i++ {
+				// halton is only stratified in 1D for powers of its base, the rotation also shifts strata
+				if samplerType != Halton && strata[i] != 1 {
+					t.Errorf("sampler %d: expected 1 sample in stratum %d, got %d", samplerType, i, strata[i])
+				}
+				if samplerType != Halton && cells[i] != 1 {
+					t.Errorf("sampler %d: expected 1 sample in cell %d, got %d", samplerType, i, cells[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSamplersConvergeFaster(t *testing.T) {
+	// estimate the area of a quarter of a circle, pi / 4, with 16 samples in each of many pixels
+	squaredError := func(samplerType SamplerType) float64 {
+		sampler := newSampler(samplerType, 16)
+		total := 0.0
+		for pixel := uint64(0); pixel < 1000; pixel++ {
+			seed := hashSeed(pixel, 0)
+			inside := 0
+			for i := 0; i < 16; i++ {
+				x, y := sampler.get2D(i, 0, seed)
+				if x*x+y*y < 1 {
+					inside++
+				}
+			}
+			total += math.Pow(float64(inside)/16-math.Pi/4, 2)
+		}
+		return total / 1000
+	}
+
+	uniform := squaredError(UniformRandom)
+	for _, samplerType := range []SamplerType{Stratified, Halton, Sobol} {
+		if e := squaredError(samplerType); e > uniform/2 {
+			t.Errorf("sampler %d: expected less than half the error of uniform random samples %f, got %f", samplerType, uniform, e)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"io"
+	"math"
+	"sort"
+)
+
+func init() {
+	// every type that can be held by the interfaces of a scene, in the form it is used in, gob can't register both
+	for _, v := range []interface{}{
+		&Sphere{}, &TrianglePlane{}, &Plane{}, &Disk{}, &Box{}, &OrientedBox{}, &Cylinder{}, &Cone{}, &Torus{},
+		&CSG{}, &SDFShape{}, &TransformedShape{}, &Instance{}, &MovingShape{}, &ConstantMedium{}, &HeterogeneousMedium{},
+		&DenseGrid{}, &SparseGrid{}, &ImageAperature{},
+		Standard{}, Metal{}, Dielectric{}, PhongBlinn{}, CheckersTexture{}, ImageTexture{},
+		AmbientLight{}, PointLight{}, SpotLight{},
+		CircularAperature{}, PolygonalAperature{}, LinearMotion{}, KeyframedMotion{},
+	} {
+		gob.Register(v)
+	}
+}
+
+// reads a scene written by WriteScene
+func LoadScene(file io.Reader) (*Scene, error) {
+	scene := Scene{}
+	if err := gob.NewDecoder(file).Decode(&scene); err != nil {
+		return nil, err
+	}
+	return &scene, nil
+}
+
+// writes a scene as a gob, eg to send it to other processes, scenes with SDFShapes can't be written
+func WriteScene(file io.Writer, scene *Scene) error {
+	return gob.NewEncoder(file).Encode(scene)
+}
+
+// what to render, as sent to render workers and the render service
+type renderJob struct {
+	ImageSpec ImageSpec
+	Scene     Scene
+}
+
+// writes the image spec and scene of a render, eg as the body of a request to the render service
+func WriteRenderJob(file io.Writer, imageSpec ImageSpec, scene *Scene) error {
+	return gob.NewEncoder(file).Encode(renderJob{ImageSpec: imageSpec, Scene: *scene})
+}
+
+func loadRenderJob(file io.Reader) (*renderJob, error) {
+	job := renderJob{}
+	if err := gob.NewDecoder(file).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// the types below keep state in unexported fields, which gob leaves out, so they encode it themselves
+
+func (c CircularAperature) GobEncode() ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (c *CircularAperature) GobDecode(data []byte) error {
+	return nil
+}
+
+func (s SDFShape) GobEncode() ([]byte, error) {
+	return nil, errors.New("SDF shapes can't be written, their distance is a Go function")
+}
+
+func (s *SDFShape) GobDecode(data []byte) error {
+	return errors.New("SDF shapes can't be read, their distance is a Go function")
+}
+
+type transformedShapeGob struct {
+	Shape     Shape
+	Transform Matrix4
+	Inverse   Matrix4
+}
+
+func (ts TransformedShape) GobEncode() ([]byte, error) {
+	return encodeGob(transformedShapeGob{Shape: ts.Shape, Transform: ts.transform, Inverse: ts.inverse})
+}
+
+func (ts *TransformedShape) GobDecode(data []byte) error {
+	g := transformedShapeGob{}
+	if err := decodeGob(data, &g); err != nil {
+		return err
+	}
+	ts.Shape, ts.transform, ts.inverse = g.Shape, g.Transform, g.Inverse
+	return nil
+}
+
+type instanceGob struct {
+	Shapes    []Shape
+	Transform Matrix4
+}
+
+// the shapes of the object are written with every instance, and each instance reads back its own object
+func (in Instance) GobEncode() ([]byte, error) {
+	return encodeGob(instanceGob{Shapes: in.Object.shapes, Transform: in.transform})
+}
+
+func (in *Instance) GobDecode(data []byte) error {
+	g := instanceGob{}
+	if err := decodeGob(data, &g); err != nil {
+		return err
+	}
+	*in = *NewInstance(NewObject(g.Shapes), g.Transform)
+	return nil
+}
+
+type denseGridGob struct {
+	Min, Max   r3.Vec
+	Nx, Ny, Nz int
+	Values     []float32
+}
+
+func (g DenseGrid) GobEncode() ([]byte, error) {
+	return encodeGob(denseGridGob{Min: g.Min, Max: g.Max, Nx: g.nx, Ny: g.ny, Nz: g.nz, Values: g.values})
+}
+
+func (g *DenseGrid) GobDecode(data []byte) error {
+	d := denseGridGob{}
+	if err := decodeGob(data, &d); err != nil {
+		return err
+	}
+	if len(d.Values) != d.Nx*d.Ny*d.Nz {
+		return fmt.Errorf("expected %d values for a %dx%dx%d grid, got %d", d.Nx*d.Ny*d.Nz, d.Nx, d.Ny, d.Nz, len(d.Values))
+	}
+	if err := checkDensities(d.Values); err != nil {
+		return err
+	}
+	*g = *NewDenseGrid(d.Min, d.Max, d.Nx, d.Ny, d.Nz, d.Values)
+	return nil
+}
+
+// blocks are written in order of their keys, so the same grid is always written the same way, eg for checkpoints
+type sparseGridGob struct {
+	Origin    r3.Vec
+	VoxelSize float64
+	Keys      [][3]int32
+	Blocks    []*sparseGridBlock
+}
+
+func (g SparseGrid) GobEncode() ([]byte, error) {
+	s := sparseGridGob{Origin: g.Origin, VoxelSize: g.VoxelSize}
+	for key := range g.blocks {
+		s.Keys = append(s.Keys, key)
+	}
+	sort.Slice(s.Keys, func(i, j int) bool {
+		a, b := s.Keys[i], s.Keys[j]
+		if a[2] != b[2] {
+			return a[2] < b[2]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[0] < b[0]
+	})
+	for _, key := range s.Keys {
+		s.Blocks = append(s.Blocks, g.blocks[key])
+	}
+	return encodeGob(s)
+}
+
+func (g *SparseGrid) GobDecode(data []byte) error {
+	s := sparseGridGob{}
+	if err := decodeGob(data, &s); err != nil {
+		return err
+	}
+	if err := checkVoxelSize(s.VoxelSize); err != nil {
+		return err
+	}
+	if len(s.Keys) != len(s.Blocks) {
+		return fmt.Errorf("expected a block for each of the %d keys of the sparse grid, got %d", len(s.Keys), len(s.Blocks))
+	}
+	// the max is worked out again rather than trusted, it ends delta tracking
+	*g = SparseGrid{Origin: s.Origin, VoxelSize: s.VoxelSize, blocks: make(map[[3]int32]*sparseGridBlock)}
+	for i, block := range s.Blocks {
+		if block == nil {
+			return fmt.Errorf("block %v of the sparse grid has no densities", s.Keys[i])
+		}
+		if err := checkDensities(block[:]); err != nil {
+			return err
+		}
+		for _, v := range block {
+			g.max = math.Max(g.max, float64(v))
+		}
+		g.blocks[s.Keys[i]] = block
+	}
+	return nil
+}
+
+type imageAperatureGob struct {
+	Width, Height int
+	Cdf           []float64
+}
+
+func (i ImageAperature) GobEncode() ([]byte, error) {
+	return encodeGob(imageAperatureGob{Width: i.width, Height: i.height, Cdf: i.cdf})
+}
+
+func (i *ImageAperature) GobDecode(data []byte) error {
+	g := imageAperatureGob{}
+	if err := decodeGob(data, &g); err != nil {
+		return err
+	}
+	i.width, i.height, i.cdf = g.Width, g.Height, g.Cdf
+	return nil
+}
+
+func encodeGob(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeGob(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
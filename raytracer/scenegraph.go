@@ -0,0 +1,116 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"reflect"
+)
+
+// named group of shapes in a scene graph, the transform and material override of a node apply to all of its children
+type SceneNode struct {
+	Name      string
+	Transform Matrix4  // relative to the parent node, the zero value is treated as the identity matrix
+	Material  Material // when set, overrides the material of every shape below this node unless a child overrides it again
+	Shapes    []Shape
+	Children  []*SceneNode
+}
+
+// replaces the material of the hit record with the material of the group the shape belongs to
+type materialOverride struct {
+	Shape
+	mat Material
+}
+
+func NewSceneNode(name string) *SceneNode {
+	return &SceneNode{
+		Name:      name,
+		Transform: IdentityMatrix(),
+	}
+}
+
+// adds a child node and returns it, so groups can be built up inline
+func (n *SceneNode) AddChild(child *SceneNode) *SceneNode {
+	n.Children = append(n.Children, child)
+	return child
+}
+
+func (n *SceneNode) AddShapes(shapes ...Shape) {
+	n.Shapes = append(n.Shapes, shapes...)
+}
+
+// depth first search for the first node with the given name, including this node
+func (n *SceneNode) FindNode(name string) *SceneNode {
+	if n.Name == name {
+		return n
+	}
+	for _, child := range n.Children {
+		if found := child.FindNode(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (n *SceneNode) Translate(tv r3.Vec) {
+	n.Transform = TranslationMatrix(tv).Multiply(n.localTransform())
+}
+
+// scales around the origin of the parent node
+func (n *SceneNode) Scale(c float64) {
+	n.Transform = ScalingMatrix(r3.Vec{X: c, Y: c, Z: c}).Multiply(n.localTransform())
+}
+
+// rotation vector is in degrees, rotates around the origin of the parent node
+func (n *SceneNode) Rotate(rv r3.Vec) {
+	n.Transform = RotationMatrix(rv).Multiply(n.localTransform())
+}
+
+func (n *SceneNode) localTransform() Matrix4 {
+	if n.Transform == (Matrix4{}) {
+		return IdentityMatrix()
+	}
+	return n.Transform
+}
+
+// flattens the hierarchy into a list of shapes in world space, ready to be put into a bounding volume hierarchy
+func (n *SceneNode) flatten() []Shape {
+	shapes := make([]Shape, 0)
+	n.flattenInto(&shapes, IdentityMatrix(), nil)
+	return shapes
+}
+
+func (n *SceneNode) flattenInto(shapes *[]Shape, parentTransform Matrix4, parentMaterial Material) {
+	worldTransform := parentTransform.Multiply(n.localTransform())
+	mat := parentMaterial
+	if n.Material != nil {
+		mat = n.Material
+	}
+
+	for _, s := range n.Shapes {
+		shape := s
+		if worldTransform != IdentityMatrix() {
+			shape = NewTransformedShape(shape, worldTransform)
+		}
+		if mat != nil {
+			shape = &materialOverride{Shape: shape, mat: mat}
+		}
+		*shapes = append(*shapes, shape)
+	}
+	for _, child := range n.Children {
+		child.flattenInto(shapes, worldTransform, mat)
+	}
+}
+
+func (m materialOverride) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	hr := m.Shape.hit(r, tMin, tMax)
+	hr.material = m.mat
+	return hr
+}
+
+func (m materialOverride) description() string {
+	return fmt.Sprintf(
+		"%s, Material Override: %s",
+		m.Shape.description(),
+		reflect.TypeOf(m.mat),
+	)
+}
@@ -0,0 +1,103 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+// nearest hit of a ray along +z from (x, y, -10) with the flattened shapes of the node
+func hitSceneNode(n *SceneNode, x float64, y float64) (bool, hitRecord) {
+	r := ray{p: r3.Vec{X: x, Y: y, Z: -10}, normalizedDirection: r3.Vec{Z: 1}}
+	nearest := hitRecord{t: math.MaxFloat64}
+	for _, s := range n.flatten() {
+		if hr := s.hit(&r, 0, nearest.t); hr.t > 0 && hr.t < nearest.t {
+			nearest = hr
+		}
+	}
+	return nearest.t != math.MaxFloat64, nearest
+}
+
+func TestSceneNodeTransforms(t *testing.T) {
+	// child 1 up from its parent, the parent scales by 2 then moves 3 along x
+	root := NewSceneNode("root")
+	root.Scale(2)
+	root.Translate(r3.Vec{X: 3})
+	child := root.AddChild(NewSceneNode("child"))
+	child.Translate(r3.Vec{Y: 1})
+	child.AddShapes(&Sphere{Radius: 0.5, Mat: Metal{}})
+
+	// the sphere is at (3, 2, 0) with a radius of 1
+	if hit, hr := hitSceneNode(root, 3, 2); !hit || math.Abs(hr.t-9) > 1e-6 {
+		t.Errorf("expected to hit the scaled sphere at t=9, got %v at %f", hit, hr.t)
+	}
+	if hit, _ := hitSceneNode(root, 3, 3.1); hit {
+		t.Errorf("expected to miss above the scaled sphere")
+	}
+	if hit, _ := hitSceneNode(root, 0, 1); hit {
+		t.Errorf("expected nothing where the sphere would be without the parent transform")
+	}
+}
+
+func TestSceneNodeZeroTransform(t *testing.T) {
+	sphere := &Sphere{Center: r3.Vec{X: 1}, Radius: 0.5, Mat: Metal{}}
+	// built without NewSceneNode, so the transform is all zeros
+	node := &SceneNode{Name: "group", Shapes: []Shape{sphere}}
+	shapes := node.flatten()
+	if len(shapes) != 1 || shapes[0] != Shape(sphere) {
+		t.Errorf("expected the shape as it is under a zero transform, got %v", shapes)
+	}
+	if hit, hr := hitSceneNode(node, 1, 0); !hit || math.Abs(hr.t-9.5) > 1e-6 {
+		t.Errorf("expected to hit the sphere in place, got %v at %f", hit, hr.t)
+	}
+
+	node.Translate(r3.Vec{Y: 2})
+	if hit, _ := hitSceneNode(node, 1, 2); !hit {
+		t.Errorf("expected a zero transform to be moved like the identity")
+	}
+}
+
+func TestSceneNodeMaterialOverrides(t *testing.T) {
+	red, green, blue := Metal{Albedo: r3.Vec{X: 1}}, Metal{Albedo: r3.Vec{Y: 1}}, Metal{Albedo: r3.Vec{Z: 1}}
+	root := NewSceneNode("root")
+	root.Material = red
+	root.AddShapes(&Sphere{Center: r3.Vec{X: -3}, Radius: 0.5, Mat: green})
+	child := root.AddChild(NewSceneNode("child"))
+	child.Material = blue
+	child.AddShapes(&Sphere{Radius: 0.5, Mat: green})
+	grandchild := child.AddChild(NewSceneNode("grandchild"))
+	grandchild.AddShapes(&Sphere{Center: r3.Vec{X: 3}, Radius: 0.5, Mat: green})
+
+	for _, c := range []struct {
+		x        float64
+		expected Material
+	}{
+		{-3, red}, // the shape's own material is overridden by its node
+		{0, blue}, // a child overrides its parent
+		{3, blue}, // the nearest ancestor with a material wins
+	} {
+		if hit, hr := hitSceneNode(root, c.x, 0); !hit || hr.material != c.expected {
+			t.Errorf("expected the sphere at x=%f to be %v, got %v", c.x, c.expected, hr.material)
+		}
+	}
+}
+
+func TestSceneNodeMovesGroup(t *testing.T) {
+	// a mirror and its frame, moved together by moving their group
+	root := NewSceneNode("room")
+	mirror := root.AddChild(NewSceneNode("mirror"))
+	mirror.AddShapes(
+		&Box{Min: r3.Vec{X: -1, Y: -1, Z: 0}, Max: r3.Vec{X: 1, Y: 1, Z: 0.1}, Mat: Metal{Albedo: r3.Vec{X: 1, Y: 1, Z: 1}}},
+		&Box{Min: r3.Vec{X: -1.2, Y: 1, Z: 0}, Max: r3.Vec{X: 1.2, Y: 1.2, Z: 0.2}, Mat: Standard{ColorFrac: r3.Vec{X: 0.5}}},
+	)
+
+	root.FindNode("mirror").Translate(r3.Vec{X: 5})
+	for _, y := range []float64{0, 1.1} {
+		if hit, _ := hitSceneNode(root, 0, y); hit {
+			t.Errorf("expected nothing left at y=%f where the mirror was", y)
+		}
+		if hit, _ := hitSceneNode(root, 5, y); !hit {
+			t.Errorf("expected the mirror and its frame to have moved, missed at y=%f", y)
+		}
+	}
+}
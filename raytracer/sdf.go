@@ -0,0 +1,234 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+)
+
+const sdfMaxSteps = 512
+const sdfHitEpsilon = 1e-4
+const sdfNormalEpsilon = 1e-4
+
+// signed distance function, returns the distance from p to the closest point on the surface
+// the distance is negative inside of the surface
+type SDF func(p r3.Vec) float64
+
+// implicit surface intersected by sphere tracing, the surface must stay inside of Min and Max
+type SDFShape struct {
+	Distance SDF
+	Min      r3.Vec
+	Max      r3.Vec
+	Mat      Material
+	// fraction of the distance to step each iteration, between (0, 1], defaults to 1
+	// lower it when combinators like displacement make the function overestimate the distance
+	StepScale float64
+}
+
+func (s SDFShape) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	tNear, tFar, ok := intersectSlabs(r.p, r.normalizedDirection, s.Min, s.Max)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+	t := math.Max(tNear, tMin)
+	tEnd := math.Min(tFar, tMax)
+	stepScale := s.StepScale
+	if stepScale <= 0 || stepScale > 1 {
+		stepScale = 1
+	}
+
+	// rays leaving the surface (eg reflections) start on the surface, so ignore hits until we have moved away from it
+	leftSurface := math.Abs(s.Distance(r.PointAtT(t))) >= sdfHitEpsilon
+	for i := 0; i < sdfMaxSteps && t <= tEnd; i++ {
+		d := math.Abs(s.Distance(r.PointAtT(t)))
+		if d < sdfHitEpsilon {
+			if leftSurface && t > tMin {
+				p := r.PointAtT(t)
+				return hitRecord{
+					t:        t,
+					p:        p,
+					normal:   sdfNormal(s.Distance, p),
+					shape:    &s,
+					material: s.Mat,
+				}
+			}
+			// step over the surface we started on
+			d = sdfHitEpsilon
+		} else {
+			leftSurface = true
+		}
+		t += d * stepScale
+	}
+	return hitRecord{t: -1}
+}
+
+// gradient of the distance function with central differences
+func sdfNormal(distance SDF, p r3.Vec) r3.Vec {
+	dx := r3.Vec{X: sdfNormalEpsilon}
+	dy := r3.Vec{Y: sdfNormalEpsilon}
+	dz := r3.Vec{Z: sdfNormalEpsilon}
+	return r3.Unit(r3.Vec{
+		X: distance(r3.Add(p, dx)) - distance(r3.Sub(p, dx)),
+		Y: distance(r3.Add(p, dy)) - distance(r3.Sub(p, dy)),
+		Z: distance(r3.Add(p, dz)) - distance(r3.Sub(p, dz)),
+	})
+}
+
+func (s *SDFShape) Translate(tv r3.Vec) {
+	s.Distance = SDFTransform(s.Distance, TranslationMatrix(tv))
+	s.Min = r3.Add(tv, s.Min)
+	s.Max = r3.Add(tv, s.Max)
+}
+
+// scales around the center of the bounds
+func (s *SDFShape) Scale(c float64) {
+	center := s.centroid()
+	distance := s.Distance
+	s.Distance = func(p r3.Vec) float64 {
+		return c * distance(r3.Add(center, r3.Scale(1/c, r3.Sub(p, center))))
+	}
+	s.Min = r3.Add(center, r3.Scale(c, r3.Sub(s.Min, center)))
+	s.Max = r3.Add(center, r3.Scale(c, r3.Sub(s.Max, center)))
+}
+
+func (s *SDFShape) Rotate(rv r3.Vec) {
+	m := RotationMatrix(rv)
+	s.Distance = SDFTransform(s.Distance, m)
+	s.Min, s.Max = transformBounds(&m, s.Min, s.Max)
+}
+
+func (s SDFShape) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	return s.Min, s.Max
+}
+
+func (s SDFShape) centroid() r3.Vec {
+	return r3.Scale(0.5, r3.Add(s.Min, s.Max))
+}
+
+// spherical projection around the center of the bounds
+func (s SDFShape) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	d := r3.Unit(r3.Sub(point, s.centroid()))
+	return (math.Atan2(-d.Z, d.X) + math.Pi) / (2 * math.Pi), math.Acos(math.Max(-1, math.Min(1, -d.Y))) / math.Pi
+}
+
+func (s SDFShape) description() string {
+	return fmt.Sprintf(
+		"%s - Min: %v, Max: %v, Material: %s",
+		reflect.TypeOf(s),
+		s.Min,
+		s.Max,
+		reflect.TypeOf(s.Mat),
+	)
+}
+
+func SDFSphere(center r3.Vec, radius float64) SDF {
+	return func(p r3.Vec) float64 {
+		return r3.Norm(r3.Sub(p, center)) - radius
+	}
+}
+
+func SDFBox(center r3.Vec, halfSize r3.Vec) SDF {
+	return func(p r3.Vec) float64 {
+		q := r3.Sub(r3.Vec{X: math.Abs(p.X - center.X), Y: math.Abs(p.Y - center.Y), Z: math.Abs(p.Z - center.Z)}, halfSize)
+		outside := r3.Norm(r3.Vec{X: math.Max(q.X, 0), Y: math.Max(q.Y, 0), Z: math.Max(q.Z, 0)})
+		inside := math.Min(math.Max(q.X, math.Max(q.Y, q.Z)), 0)
+		return outside + inside
+	}
+}
+
+// torus lying flat, around the y axis
+func SDFTorus(center r3.Vec, majorRadius float64, minorRadius float64) SDF {
+	return func(p r3.Vec) float64 {
+		local := r3.Sub(p, center)
+		ring := math.Sqrt(local.X*local.X+local.Z*local.Z) - majorRadius
+		return math.Sqrt(ring*ring+local.Y*local.Y) - minorRadius
+	}
+}
+
+// mandelbulb fractal centered at the origin with a radius of about 1.2, power 8 gives the classic shape
+// this is a distance estimate rather than an exact distance, so a StepScale below 1 is recommended
+func SDFMandelbulb(power float64, iterations int) SDF {
+	return func(p r3.Vec) float64 {
+		z := p
+		dr := 1.0
+		r := 0.0
+		for i := 0; i < iterations; i++ {
+			r = r3.Norm(z)
+			if r > 2 {
+				break
+			}
+			theta := math.Acos(z.Z/r) * power
+			phi := math.Atan2(z.Y, z.X) * power
+			dr = math.Pow(r, power-1)*power*dr + 1
+			zr := math.Pow(r, power)
+			z = r3.Add(r3.Scale(zr, r3.Vec{X: math.Sin(theta) * math.Cos(phi), Y: math.Sin(phi) * math.Sin(theta), Z: math.Cos(theta)}), p)
+		}
+		return 0.5 * math.Log(r) * r / dr
+	}
+}
+
+func SDFUnion(a SDF, b SDF) SDF {
+	return func(p r3.Vec) float64 {
+		return math.Min(a(p), b(p))
+	}
+}
+
+func SDFIntersection(a SDF, b SDF) SDF {
+	return func(p r3.Vec) float64 {
+		return math.Max(a(p), b(p))
+	}
+}
+
+// a minus b
+func SDFDifference(a SDF, b SDF) SDF {
+	return func(p r3.Vec) float64 {
+		return math.Max(a(p), -b(p))
+	}
+}
+
+// union that rounds off where the surfaces meet, k is the size of the rounded area
+func SDFSmoothUnion(a SDF, b SDF, k float64) SDF {
+	return func(p r3.Vec) float64 {
+		da := a(p)
+		db := b(p)
+		h := saturate(0.5 + 0.5*(db-da)/k)
+		return db*(1-h) + da*h - k*h*(1-h)
+	}
+}
+
+// morphs between two shapes, f of 0 gives a and f of 1 gives b
+func SDFBlend(a SDF, b SDF, f float64) SDF {
+	return func(p r3.Vec) float64 {
+		return (1-f)*a(p) + f*b(p)
+	}
+}
+
+// repeats the shape infinitely in a grid of cells of the given size, a size of 0 on an axis means no repetition on it
+// the shape should be centered at the origin and fit in one cell
+func SDFRepeat(sdf SDF, cellSize r3.Vec) SDF {
+	repeat := func(x float64, size float64) float64 {
+		if size == 0 {
+			return x
+		}
+		return x - size*math.Round(x/size)
+	}
+	return func(p r3.Vec) float64 {
+		return sdf(r3.Vec{X: repeat(p.X, cellSize.X), Y: repeat(p.Y, cellSize.Y), Z: repeat(p.Z, cellSize.Z)})
+	}
+}
+
+// moves the surface along its normal by the displacement, eg for noise or ripples
+func SDFDisplace(sdf SDF, displacement func(p r3.Vec) float64) SDF {
+	return func(p r3.Vec) float64 {
+		return sdf(p) + displacement(p)
+	}
+}
+
+// applies a rigid transform (translation and rotation) to the shape, scaling would distort the distances
+func SDFTransform(sdf SDF, m Matrix4) SDF {
+	inverse := m.Inverse()
+	return func(p r3.Vec) float64 {
+		return sdf(inverse.TransformPoint(p))
+	}
+}
@@ -0,0 +1,62 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestSDFShapeMatchesSphere(t *testing.T) {
+	sphere := Sphere{Center: r3.Vec{X: 1, Y: 2, Z: 3}, Radius: 2}
+	sdf := SDFShape{
+		Distance: SDFSmoothUnion(SDFSphere(sphere.Center, sphere.Radius), SDFSphere(r3.Vec{X: 100}, 1), 0.1),
+		Min:      r3.Vec{X: -1, Y: 0, Z: 1},
+		Max:      r3.Vec{X: 3, Y: 4, Z: 5},
+	}
+	r := ray{p: r3.Vec{X: -5, Y: 0, Z: 0}, normalizedDirection: r3.Unit(r3.Vec{X: 6, Y: 2.5, Z: 3})}
+	expected := sphere.hit(&r, 0, math.MaxFloat64)
+	got := sdf.hit(&r, 0, math.MaxFloat64)
+	if math.Abs(got.t-expected.t) > 1e-3 {
+		t.Errorf("expected sphere traced hit at t=%f, got t=%f", expected.t, got.t)
+	}
+	if r3.Norm(r3.Sub(got.normal, expected.normal)) > 1e-3 {
+		t.Errorf("expected sphere traced normal %v, got %v", expected.normal, got.normal)
+	}
+
+	// reflected rays start on the surface and must not hit it again straight away
+	reflectedRay := ray{p: got.p, normalizedDirection: reflected(&r.normalizedDirection, &got.normal)}
+	if hr := sdf.hit(&reflectedRay, 0, math.MaxFloat64); hr.t > 0 {
+		t.Errorf("reflected ray hit the surface it started on at t=%f", hr.t)
+	}
+}
+
+func TestSDFCombinators(t *testing.T) {
+	// unit spheres overlapping around x=0.75
+	a := SDFSphere(r3.Vec{}, 1)
+	b := SDFSphere(r3.Vec{X: 1.5}, 1)
+	tests := []struct {
+		name     string
+		sdf      SDF
+		p        r3.Vec
+		expected float64
+	}{
+		{"union outside", SDFUnion(a, b), r3.Vec{X: -2}, 1},
+		{"union inside", SDFUnion(a, b), r3.Vec{}, -1},
+		{"intersection outside the second", SDFIntersection(a, b), r3.Vec{}, 0.5},
+		{"intersection inside both", SDFIntersection(a, b), r3.Vec{X: 0.75}, -0.25},
+		{"difference inside the second", SDFDifference(a, b), r3.Vec{X: 0.75}, 0.25},
+		{"difference inside the first", SDFDifference(a, b), r3.Vec{}, -0.5},
+		{"smooth union away from the seam", SDFSmoothUnion(a, b, 0.1), r3.Vec{X: -2}, 1},
+		{"smooth union on the seam", SDFSmoothUnion(a, b, 0.1), r3.Vec{X: 0.75}, -0.275},
+		{"blend", SDFBlend(a, b, 0.5), r3.Vec{}, -0.25},
+		{"repeat", SDFRepeat(SDFSphere(r3.Vec{}, 0.5), r3.Vec{X: 4}), r3.Vec{X: 8.5}, 0},
+		{"repeat between cells", SDFRepeat(SDFSphere(r3.Vec{}, 0.5), r3.Vec{X: 4}), r3.Vec{X: 2}, 1.5},
+		{"transform", SDFTransform(a, TranslationMatrix(r3.Vec{Y: 2})), r3.Vec{Y: 2}, -1},
+		{"displace", SDFDisplace(a, func(p r3.Vec) float64 { return 0.5 }), r3.Vec{X: 2}, 1.5},
+	}
+	for _, test := range tests {
+		if d := test.sdf(test.p); math.Abs(d-test.expected) > 1e-9 {
+			t.Errorf("%s: expected distance %f at %v, got %f", test.name, test.expected, test.p, d)
+		}
+	}
+}
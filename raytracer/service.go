@@ -0,0 +1,349 @@
+package raytracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// state of a job of the render service
+const (
+	jobQueued    = "queued"
+	jobRendering = "rendering"
+	jobDone      = "done"
+	jobFailed    = "failed"
+)
+
+// limits on the jobs a client can send, so one request can't make the service run out of memory
+const (
+	maxRenderJobBytes   = 256 << 20
+	maxRenderJobPixels  = 4096 * 4096
+	maxRenderJobWorkers = 256
+)
+
+// limits on the jobs kept, so many requests can't make the service run out of memory either
+const (
+	defaultMaxQueuedJobs   = 64
+	defaultMaxFinishedJobs = 64
+	defaultJobRetention    = time.Hour
+)
+
+// http service that renders scenes sent to it, jobs wait in a queue until one of Concurrency renders is free
+// and are rendered in the order they were queued, a full queue answers 503
+// finished and failed jobs are dropped after the retention period, or when there are more of them than the max
+//
+//	POST /jobs                 queues a render written by WriteRenderJob, answers with the status of the job
+//	GET /jobs                  status of every job
+//	GET /jobs/{id}             status of a job, with the progress of its render
+//	GET /jobs/{id}/image.png   finished image
+//	GET /jobs/{id}/image.exr   finished image with the linear colors
+//	GET /jobs/{id}/preview.png image so far, the tiles not rendered yet are black
+//	DELETE /jobs/{id}          drops a job that isn't rendering
+type RenderService struct {
+	MaxQueuedJobs   int // jobs waiting for a render, not counting the ones rendering
+	MaxFinishedJobs int // done or failed jobs kept, the oldest are dropped first
+	JobRetention    time.Duration
+
+	lock        sync.Mutex
+	jobs        map[string]*serviceJob
+	order       []string // ids of the jobs in the order they were queued
+	nextID      int
+	concurrency int
+	rendering   int
+}
+
+type serviceJob struct {
+	id        string
+	imageSpec ImageSpec
+	scene     Scene
+	status    string
+	err       string
+	queued    time.Time
+	started   time.Time
+	finished  time.Time
+	render    *ProgressiveRender // once the job has started rendering
+}
+
+// what GET /jobs/{id} answers with
+type JobStatus struct {
+	ID       string     `json:"id"`
+	Status   string     `json:"status"`
+	Progress float64    `json:"progress"` // fraction of the tiles rendered
+	Error    string     `json:"error,omitempty"`
+	Width    int        `json:"width"`
+	Height   int        `json:"height"`
+	Queued   time.Time  `json:"queued"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+func NewRenderService(concurrency int) *RenderService {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &RenderService{
+		MaxQueuedJobs:   defaultMaxQueuedJobs,
+		MaxFinishedJobs: defaultMaxFinishedJobs,
+		JobRetention:    defaultJobRetention,
+		jobs:            make(map[string]*serviceJob),
+		concurrency:     concurrency,
+	}
+}
+
+func (s *RenderService) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		http.NotFound(rw, req)
+		return
+	}
+	s.lock.Lock()
+	s.expireJobs()
+	s.lock.Unlock()
+	if len(parts) == 1 {
+		switch req.Method {
+		case http.MethodPost:
+			s.queueJob(rw, req)
+		case http.MethodGet:
+			s.lock.Lock()
+			statuses := make([]JobStatus, 0, len(s.order))
+			for _, id := range s.order {
+				statuses = append(statuses, s.jobs[id].jobStatus())
+			}
+			s.lock.Unlock()
+			writeJSON(rw, http.StatusOK, statuses)
+		default:
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	s.lock.Lock()
+	job, ok := s.jobs[parts[1]]
+	s.lock.Unlock()
+	if !ok {
+		http.Error(rw, "no job "+parts[1], http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 2 && req.Method == http.MethodGet:
+		s.lock.Lock()
+		status := job.jobStatus()
+		s.lock.Unlock()
+		writeJSON(rw, http.StatusOK, status)
+	case len(parts) == 2 && req.Method == http.MethodDelete:
+		s.deleteJob(rw, job)
+	case len(parts) == 3 && req.Method == http.MethodGet:
+		s.serveImage(rw, job, parts[2])
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *RenderService) queueJob(rw http.ResponseWriter, req *http.Request) {
+	renderJob, err := loadRenderJob(http.MaxBytesReader(rw, req.Body, maxRenderJobBytes))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := renderJob.validate(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	queued := 0
+	for _, job := range s.jobs {
+		if job.status == jobQueued {
+			queued++
+		}
+	}
+	if queued >= s.MaxQueuedJobs {
+		s.lock.Unlock()
+		http.Error(rw, fmt.Sprintf("%d jobs are queued already", queued), http.StatusServiceUnavailable)
+		return
+	}
+	s.nextID++
+	job := &serviceJob{
+		id:        fmt.Sprintf("%d", s.nextID),
+		imageSpec: renderJob.ImageSpec,
+		scene:     renderJob.Scene,
+		status:    jobQueued,
+		queued:    time.Now(),
+	}
+	s.jobs[job.id] = job
+	s.order = append(s.order, job.id)
+	status := job.jobStatus()
+	s.startJobs()
+	s.lock.Unlock()
+
+	rw.Header().Set("Location", "/jobs/"+job.id)
+	writeJSON(rw, http.StatusAccepted, status)
+}
+
+// starts the oldest queued jobs while there are free renders, the lock of the service must be held
+func (s *RenderService) startJobs() {
+	for _, id := range s.order {
+		if s.rendering >= s.concurrency {
+			return
+		}
+		if job := s.jobs[id]; job.status == jobQueued {
+			job.status = jobRendering
+			job.started = time.Now()
+			s.rendering++
+			go s.renderJob(job)
+		}
+	}
+}
+
+// drops the done and failed jobs past the retention period, then the oldest ones over the max
+// the lock of the service must be held
+func (s *RenderService) expireJobs() {
+	finished := 0
+	for i := len(s.order) - 1; i >= 0; i-- {
+		job := s.jobs[s.order[i]]
+		if job.status != jobDone && job.status != jobFailed {
+			continue
+		}
+		finished++
+		if finished > s.MaxFinishedJobs || time.Since(job.finished) > s.JobRetention {
+			delete(s.jobs, job.id)
+			s.order = append(s.order[:i], s.order[i+1:]...)
+		}
+	}
+}
+
+// renders a job started by startJobs, then starts the next one
+func (s *RenderService) renderJob(job *serviceJob) {
+	defer func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		job.finished = time.Now()
+		if r := recover(); r != nil {
+			job.status = jobFailed
+			job.err = fmt.Sprint(r)
+		} else {
+			job.status = jobDone
+		}
+		s.rendering--
+		s.expireJobs()
+		s.startJobs()
+	}()
+	render := NewProgressiveRender(job.imageSpec, job.scene)
+	s.lock.Lock()
+	job.render = render
+	s.lock.Unlock()
+	render.RenderPass()
+}
+
+func (s *RenderService) deleteJob(rw http.ResponseWriter, job *serviceJob) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if job.status == jobRendering {
+		http.Error(rw, "job "+job.id+" is rendering", http.StatusConflict)
+		return
+	}
+	job.status = jobFailed
+	job.err = "deleted"
+	delete(s.jobs, job.id)
+	for i, id := range s.order {
+		if id == job.id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (s *RenderService) serveImage(rw http.ResponseWriter, job *serviceJob, name string) {
+	s.lock.Lock()
+	status, render := job.status, job.render
+	s.lock.Unlock()
+	if name == "preview.png" {
+		if render == nil {
+			http.Error(rw, "job "+job.id+" hasn't started rendering", http.StatusConflict)
+			return
+		}
+		rw.Header().Set("Content-Type", "image/png")
+		png.Encode(rw, render.Snapshot())
+		return
+	}
+	if name != "image.png" && name != "image.exr" {
+		http.Error(rw, "no image "+name, http.StatusNotFound)
+		return
+	}
+	if status != jobDone {
+		http.Error(rw, "job "+job.id+" is "+status, http.StatusConflict)
+		return
+	}
+	if name == "image.png" {
+		rw.Header().Set("Content-Type", "image/png")
+		png.Encode(rw, render.Snapshot())
+		return
+	}
+	rw.Header().Set("Content-Type", "image/x-exr")
+	WriteEXR(rw, render.Framebuffer())
+}
+
+// the lock of the service must be held
+func (j *serviceJob) jobStatus() JobStatus {
+	status := JobStatus{
+		ID:     j.id,
+		Status: j.status,
+		Error:  j.err,
+		Width:  j.imageSpec.Width,
+		Height: j.imageSpec.Height,
+		Queued: j.queued,
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		status.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		status.Finished = &finished
+	}
+	if j.status == jobDone {
+		status.Progress = 1
+	} else if j.render != nil {
+		status.Progress = j.render.Progress()
+	}
+	return status
+}
+
+// checks the job before it is queued, a bad setting panics in the render workers where it can't be recovered from
+func (job *renderJob) validate() error {
+	is := &job.ImageSpec
+	// divided rather than multiplied, so huge sizes can't overflow
+	if is.Width <= 0 || is.Height <= 0 || is.Width > maxRenderJobPixels/is.Height {
+		return fmt.Errorf("image of %dx%d pixels, expected at least 1 and at most %d pixels", is.Width, is.Height, maxRenderJobPixels)
+	}
+	if is.WorkerCount <= 0 || is.WorkerCount > maxRenderJobWorkers {
+		return fmt.Errorf("worker count of %d, expected from 1 to %d", is.WorkerCount, maxRenderJobWorkers)
+	}
+	if is.AntiAliasingFactor <= 0 {
+		return fmt.Errorf("anti aliasing factor of %d, expected at least 1", is.AntiAliasingFactor)
+	}
+	if is.Sampler < UniformRandom || is.Sampler > Sobol {
+		return fmt.Errorf("no sampler found for %d", is.Sampler)
+	}
+	if is.BvhTraversalAlgorithm < Dijkstra || is.BvhTraversalAlgorithm > DepthFirstSearch {
+		return fmt.Errorf("no trace algorithm found for %d", is.BvhTraversalAlgorithm)
+	}
+	if is.TileOrder < Scanline || is.TileOrder > Hilbert {
+		return fmt.Errorf("no tile order found for %d", is.TileOrder)
+	}
+	if is.CropOutput < CropToRegion || is.CropOutput > FullFrame {
+		return fmt.Errorf("no crop output found for %d", is.CropOutput)
+	}
+	return job.Scene.validate()
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(v)
+}
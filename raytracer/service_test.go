@@ -0,0 +1,280 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderService(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	// jobs print their progress while they render, so stdout stays silenced until they are done
+	withoutStdout(t, func() {
+		server := httptest.NewServer(NewRenderService(1))
+		defer server.Close()
+
+		var ids []string
+		for i := 0; i < 2; i++ {
+			ids = append(ids, postJob(t, server.URL, imageSpec, scene).ID)
+		}
+
+		var statuses []JobStatus
+		getJSON(t, server.URL+"/jobs", &statuses)
+		if len(statuses) != 2 {
+			t.Fatalf("expected 2 jobs, got %d", len(statuses))
+		}
+
+		for _, id := range ids {
+			if status := waitForJob(t, server.URL, id); status.Status != jobDone || status.Progress != 1 {
+				t.Errorf("expected job %s to be done at progress 1, got %+v", id, status)
+			}
+		}
+		img := getPNG(t, server.URL+"/jobs/"+ids[0]+"/image.png")
+		expected := GenerateImage(imageSpec, scene)
+		if !bytes.Equal(img.(*image.RGBA).Pix, expected.Pix) {
+			t.Errorf("expected the service to render the same image as GenerateImage")
+		}
+		// the preview of a finished job is the finished image
+		if preview := getPNG(t, server.URL+"/jobs/"+ids[0]+"/preview.png"); !bytes.Equal(preview.(*image.RGBA).Pix, expected.Pix) {
+			t.Errorf("expected the preview of a finished job to be the image")
+		}
+
+		resp, err := http.Get(server.URL + "/jobs/" + ids[1] + "/image.exr")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected the EXR image, got %s", resp.Status)
+		}
+
+		req, _ := http.NewRequest(http.MethodDelete, server.URL+"/jobs/"+ids[1], nil)
+		if resp, err = http.DefaultClient.Do(req); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp, err = http.Get(server.URL + "/jobs/" + ids[1]); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected a deleted job to be gone, got %s", resp.Status)
+		}
+	})
+}
+
+func TestRenderServiceConcurrency(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.AntiAliasingFactor = 16
+	withoutStdout(t, func() {
+		server := httptest.NewServer(NewRenderService(2))
+		defer server.Close()
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			ids = append(ids, postJob(t, server.URL, imageSpec, scene).ID)
+		}
+		// the third job waits for one of the first two to finish
+		var status JobStatus
+		getJSON(t, server.URL+"/jobs/"+ids[2], &status)
+		if status.Status == jobQueued {
+			if status.Started != nil || status.Finished != nil {
+				t.Errorf("expected a queued job to have no start or finish time, got %+v", status)
+			}
+			resp, err := http.Get(server.URL + "/jobs/" + ids[2] + "/preview.png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusConflict {
+				t.Errorf("expected no preview of a queued job, got %s", resp.Status)
+			}
+		}
+
+		statuses := []JobStatus{}
+		for _, id := range ids {
+			statuses = append(statuses, waitForJob(t, server.URL, id))
+		}
+		if !statuses[1].Started.Before(*statuses[0].Finished) {
+			t.Errorf("expected the first two jobs to render at the same time, got %+v and %+v", statuses[0], statuses[1])
+		}
+		if statuses[2].Started.Before(*statuses[0].Finished) && statuses[2].Started.Before(*statuses[1].Finished) {
+			t.Errorf("expected the third job to wait for a free slot, got %+v", statuses[2])
+		}
+	})
+}
+
+func TestRenderServiceFailedJobs(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.Width, imageSpec.Height = 40, 30
+	withoutStdout(t, func() {
+		server := httptest.NewServer(NewRenderService(1))
+		defer server.Close()
+
+		// settings that would panic in the render workers are turned away
+		for _, bad := range []func(is *ImageSpec){
+			func(is *ImageSpec) { is.Sampler = 99 },
+			func(is *ImageSpec) { is.BvhTraversalAlgorithm = 99 },
+			func(is *ImageSpec) { is.CropOutput = 99 },
+			func(is *ImageSpec) { is.Width, is.Height = 1<<20, 1<<20 },
+			func(is *ImageSpec) { is.WorkerCount = 0 },
+		} {
+			badSpec := imageSpec
+			bad(&badSpec)
+			resp := sendJob(t, server.URL, badSpec, scene)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("expected %+v to be a bad request, got %s", badSpec, resp.Status)
+			}
+		}
+		badScene := scene
+		badScene.CameraProjection = 99
+		resp := sendJob(t, server.URL, imageSpec, badScene)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected an unknown camera projection to be a bad request, got %s", resp.Status)
+		}
+		badScene = scene
+		badScene.CameraFocusPoint = &r3.Vec{Z: -10}
+		resp = sendJob(t, server.URL, imageSpec, badScene)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a focus point behind the camera to be a bad request, got %s", resp.Status)
+		}
+
+		// a panic while rendering fails the job and the service goes on
+		badScene = scene
+		badScene.Shapes = append([]Shape{}, scene.Shapes...)
+		badScene.Shapes = append(badScene.Shapes, &CSG{Operation: 99, Left: &Sphere{Radius: 1, Mat: Metal{}}, Right: &Sphere{Center: r3.Vec{X: 0.5}, Radius: 1, Mat: Metal{}}})
+		failed := waitForJob(t, server.URL, postJob(t, server.URL, imageSpec, badScene).ID)
+		if failed.Status != jobFailed || !strings.Contains(failed.Error, "No CSG operation found") {
+			t.Errorf("expected the job to fail with the panic of the render, got %+v", failed)
+		}
+		if status := waitForJob(t, server.URL, postJob(t, server.URL, imageSpec, scene).ID); status.Status != jobDone {
+			t.Errorf("expected a job after the failed one to render, got %+v", status)
+		}
+	})
+}
+
+func TestRenderServiceLimits(t *testing.T) {
+	imageSpec, scene := benchmarkScene()
+	withoutStdout(t, func() {
+		service := NewRenderService(1)
+		service.MaxQueuedJobs = 1
+		service.MaxFinishedJobs = 1
+		server := httptest.NewServer(service)
+		defer server.Close()
+
+		// one job rendering and one waiting fill the queue
+		slowSpec := imageSpec
+		slowSpec.AntiAliasingFactor = 16
+		first := postJob(t, server.URL, slowSpec, scene).ID
+		smallSpec := imageSpec
+		smallSpec.Width, smallSpec.Height = 40, 30
+		second := postJob(t, server.URL, smallSpec, scene).ID
+		var status JobStatus
+		if getJSON(t, server.URL+"/jobs/"+first, &status); status.Status == jobRendering {
+			resp := sendJob(t, server.URL, smallSpec, scene)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("expected a job to be turned away when the queue is full, got %s", resp.Status)
+			}
+		}
+
+		// only the newest finished job is kept, the second job starts when the first is done
+		waitForJob(t, server.URL, second)
+		var statuses []JobStatus
+		getJSON(t, server.URL+"/jobs", &statuses)
+		if len(statuses) != 1 || statuses[0].ID != second {
+			t.Errorf("expected only job %s to be kept, got %+v", second, statuses)
+		}
+
+		// and not for longer than the retention period
+		service.lock.Lock()
+		service.JobRetention = 0
+		service.lock.Unlock()
+		resp, err := http.Get(server.URL + "/jobs/" + second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected a job past the retention period to be gone, got %s", resp.Status)
+		}
+	})
+}
+
+// queues a job, the caller closes the body of the response
+func sendJob(t *testing.T, url string, imageSpec ImageSpec, scene Scene) *http.Response {
+	var body bytes.Buffer
+	if err := WriteRenderJob(&body, imageSpec, &scene); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url+"/jobs", "application/octet-stream", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func postJob(t *testing.T, url string, imageSpec ImageSpec, scene Scene) JobStatus {
+	resp := sendJob(t, url, imageSpec, scene)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected the job to be accepted, got %s", resp.Status)
+	}
+	var status JobStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+// polls the job until it is done or failed
+func waitForJob(t *testing.T, url string, id string) JobStatus {
+	deadline := time.Now().Add(time.Minute)
+	var status JobStatus
+	for status.Status != jobDone && status.Status != jobFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected job %s to finish, got %+v", id, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		getJSON(t, url+"/jobs/"+id, &status)
+	}
+	return status
+}
+
+func getPNG(t *testing.T, url string) image.Image {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected an image from %s, got %s", url, resp.Status)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -13,6 +13,7 @@ type hitRecord struct {
 	normal   r3.Vec
 	shape    Shape
 	material Material
+	shapeID  int // index of the render shape hit plus one, only set when rendering AOVs
 }
 
 type Shape interface {
@@ -31,9 +32,10 @@ type Shape interface {
 }
 
 type Sphere struct {
-	Center r3.Vec
-	Radius float64
-	Mat    Material
+	Center      r3.Vec
+	Radius      float64
+	Mat         Material
+	Orientation Quaternion // rotation around the center, only visible on textured spheres
 }
 
 type TrianglePlane struct {
@@ -85,7 +87,9 @@ func (s *Sphere) Scale(c float64) {
 	s.Radius *= c
 }
 
+// rotates the sphere around its own center
 func (s *Sphere) Rotate(rv r3.Vec) {
+	s.Orientation = QuaternionFromEuler(rv).Multiply(s.Orientation)
 }
 
 func (s Sphere) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
@@ -99,6 +103,9 @@ func (s Sphere) centroid() r3.Vec {
 // https://people.cs.clemson.edu/~dhouse/courses/405/notes/texture-maps.pdf
 func (s Sphere) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
 	pointWhenSphereAtOrigin := r3.Sub(point, s.Center)
+	if !s.Orientation.isZero() {
+		pointWhenSphereAtOrigin = s.Orientation.Conjugate().Rotate(pointWhenSphereAtOrigin)
+	}
 	theta := math.Atan2(-1*pointWhenSphereAtOrigin.Z, pointWhenSphereAtOrigin.X)
 	phi := math.Acos(-1 * pointWhenSphereAtOrigin.Y / s.Radius)
 	return (theta + math.Pi) / (2 * math.Pi), phi / math.Pi
@@ -168,10 +175,12 @@ func (t *TrianglePlane) Translate(tv r3.Vec) {
 	t.PointC = r3.Add(tv, t.PointC)
 }
 
+// scales around the centroid of the triangle
 func (t *TrianglePlane) Scale(c float64) {
-	t.PointA = r3.Scale(c, t.PointA)
-	t.PointB = r3.Scale(c, t.PointB)
-	t.PointC = r3.Scale(c, t.PointC)
+	center := t.centroid()
+	t.PointA = r3.Add(center, r3.Scale(c, r3.Sub(t.PointA, center)))
+	t.PointB = r3.Add(center, r3.Scale(c, r3.Sub(t.PointB, center)))
+	t.PointC = r3.Add(center, r3.Scale(c, r3.Sub(t.PointC, center)))
 }
 
 func (t *TrianglePlane) Rotate(rv r3.Vec) {
@@ -0,0 +1,90 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+)
+
+type StereoLayout int
+
+const (
+	SideBySide = iota // left eye on the left half of the image, twice as wide as ImageSpec.Width
+	OverUnder         // left eye on the top half of the image, twice as high as ImageSpec.Height
+	Anaglyph          // red from the left eye and green and blue from the right eye, for red-cyan glasses
+)
+
+type StereoSpec struct {
+	InterpupillaryDistance float64 // distance between the eyes in world units, eg 0.064 for 1 world unit per metre
+	// distance from the camera where both eyes look at the same point, shapes there appear at the depth of the screen
+	// defaults to the focus distance
+	ConvergenceDistance float64
+	Layout              StereoLayout
+}
+
+// renders the scene once for each eye, the eyes are moved apart from the camera of the scene to either side
+// only the Perspective projection is supported
+func GenerateStereoImage(imageSpec ImageSpec, scene Scene, stereo StereoSpec) *image.RGBA {
+	if scene.CameraProjection != Perspective {
+		panic(fmt.Sprintf("No stereo rendering found for camera projection %d", scene.CameraProjection))
+	}
+	shapes := scene.renderShapes()
+	bvh := NewBoundingVolumeHierarchy(&shapes)
+	// the eyes are put together at full size, a crop leaves the rest of each eye transparent
+	imageSpec.CropOutput = FullFrame
+
+	fmt.Printf("Rendering left eye\n")
+	left := traceCameraImage(imageSpec, scene, scene.eyeCamera(&imageSpec, &stereo, -1), bvh)
+	fmt.Printf("Rendering right eye\n")
+	right := traceCameraImage(imageSpec, scene, scene.eyeCamera(&imageSpec, &stereo, 1), bvh)
+	return composeStereo(left, right, stereo.Layout)
+}
+
+// camera of one eye, side is -1 for the left eye and 1 for the right eye
+// the eyes look in parallel with their views shifted to line up at the convergence distance, which avoids the
+// vertical parallax of turning the eyes towards each other
+func (s Scene) eyeCamera(imageSpec *ImageSpec, stereo *StereoSpec, side float64) camera {
+	aspect := float64(imageSpec.Width) / float64(imageSpec.Height)
+	fov, aperature := s.lens(aspect)
+	focusDistance := s.focusDistance()
+	convergenceDistance := stereo.ConvergenceDistance
+	if convergenceDistance <= 0 {
+		convergenceDistance = focusDistance
+	}
+
+	frame := newCameraFrame(s.CameraLookFrom, s.CameraLookAt, s.CameraUp)
+	eyeOffset := r3.Scale(side*stereo.InterpupillaryDistance/2, frame.u)
+	cam := NewCamera(r3.Add(s.CameraLookFrom, eyeOffset), r3.Add(s.CameraLookAt, eyeOffset), s.CameraUp, fov, aspect, aperature, focusDistance)
+	cam.setAperature(s.CameraAperatureShape)
+	// the center of the view at the focus plane is on the line from the eye to the convergence point
+	cam.lowerLeftCorner = r3.Sub(cam.lowerLeftCorner, r3.Scale(focusDistance/convergenceDistance, eyeOffset))
+	return &cam
+}
+
+func composeStereo(left *image.RGBA, right *image.RGBA, layout StereoLayout) *image.RGBA {
+	width := left.Bounds().Dx()
+	height := left.Bounds().Dy()
+	switch layout {
+	case SideBySide:
+		res := image.NewRGBA(image.Rect(0, 0, 2*width, height))
+		for y := 0; y < height; y++ {
+			copy(res.Pix[res.PixOffset(0, y):], left.Pix[left.PixOffset(0, y):left.PixOffset(width, y)])
+			copy(res.Pix[res.PixOffset(width, y):], right.Pix[right.PixOffset(0, y):right.PixOffset(width, y)])
+		}
+		return res
+	case OverUnder:
+		res := image.NewRGBA(image.Rect(0, 0, width, 2*height))
+		copy(res.Pix, left.Pix)
+		copy(res.Pix[res.PixOffset(0, height):], right.Pix)
+		return res
+	case Anaglyph:
+		res := image.NewRGBA(image.Rect(0, 0, width, height))
+		copy(res.Pix, right.Pix)
+		for i := 0; i < len(res.Pix); i += 4 {
+			res.Pix[i] = left.Pix[i]
+		}
+		return res
+	default:
+		panic(fmt.Sprintf("No stereo layout found for %d", layout))
+	}
+}
@@ -0,0 +1,50 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"testing"
+)
+
+func TestStereoEyesConverge(t *testing.T) {
+	scene := Scene{
+		CameraLookFrom: r3.Vec{Z: -10},
+		CameraLookAt:   r3.Vec{},
+		CameraUp:       r3.Vec{Y: 1},
+		CameraFov:      60,
+	}
+	imageSpec := ImageSpec{Width: 4, Height: 3}
+	stereo := StereoSpec{InterpupillaryDistance: 0.5, ConvergenceDistance: 4}
+	convergencePoint := r3.Vec{Z: -6}
+	for _, side := range []float64{-1, 1} {
+		r := scene.eyeCamera(&imageSpec, &stereo, side).getRay(0.5, 0.5, nil)
+		if r3.Norm(r3.Sub(r.p, r3.Vec{X: -side * 0.25, Z: -10})) > 1e-9 {
+			t.Errorf("expected the eye on side %f to be 0.25 from the camera, got %v", side, r.p)
+		}
+		p := r.PointAtT((convergencePoint.Z - r.p.Z) / r.normalizedDirection.Z)
+		if r3.Norm(r3.Sub(p, convergencePoint)) > 1e-9 {
+			t.Errorf("expected the center of the view on side %f to pass through %v, got %v", side, convergencePoint, p)
+		}
+	}
+}
+
+func TestComposeStereo(t *testing.T) {
+	left := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	right := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	for i := range left.Pix {
+		left.Pix[i] = 10
+		right.Pix[i] = 20
+	}
+	sideBySide := composeStereo(left, right, SideBySide)
+	if sideBySide.Bounds().Dx() != 4 || sideBySide.Pix[sideBySide.PixOffset(1, 0)] != 10 || sideBySide.Pix[sideBySide.PixOffset(2, 0)] != 20 {
+		t.Errorf("expected the left eye on the left and the right eye on the right, got %v", sideBySide.Pix)
+	}
+	overUnder := composeStereo(left, right, OverUnder)
+	if overUnder.Bounds().Dy() != 2 || overUnder.Pix[overUnder.PixOffset(0, 0)] != 10 || overUnder.Pix[overUnder.PixOffset(0, 1)] != 20 {
+		t.Errorf("expected the left eye over the right eye, got %v", overUnder.Pix)
+	}
+	anaglyph := composeStereo(left, right, Anaglyph)
+	if anaglyph.Pix[0] != 10 || anaglyph.Pix[1] != 20 || anaglyph.Pix[2] != 20 {
+		t.Errorf("expected red from the left eye and green and blue from the right eye, got %v", anaglyph.Pix[:4])
+	}
+}
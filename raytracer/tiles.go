@@ -0,0 +1,117 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"sort"
+)
+
+const defaultTileSize = 32
+
+type TileOrder int
+
+// order tiles are handed out to workers in
+const (
+	Scanline = iota // rows of tiles from the top of the image down
+	Spiral          // outwards from the center of the image, so the subject shows up first
+	Hilbert         // along a hilbert curve, so tiles rendered one after another are close together
+)
+
+// rectangle of pixels rendered by one worker, from x0, y0 up to but not including x1, y1
+// y goes from the top of the image down, like the Framebuffer
+type tile struct {
+	x0, y0 int
+	x1, y1 int
+}
+
+func (t tile) pixelCount() int {
+	return (t.x1 - t.x0) * (t.y1 - t.y0)
+}
+
+// colors of the pixels of a rendered tile and the samples taken of them, row by row from the top left
+type tileResult struct {
+	Colors  []r3.Vec
+	Samples []int
+}
+
+// splits the image into tiles, the tiles on the right and bottom edges are smaller when the size doesn't divide the image
+func imageTiles(width int, height int, tileSize int, order TileOrder) []tile {
+	if tileSize <= 0 {
+		tileSize = defaultTileSize
+	}
+	columns := (width + tileSize - 1) / tileSize
+	rows := (height + tileSize - 1) / tileSize
+	newTile := func(column int, row int) tile {
+		return tile{
+			x0: column * tileSize,
+			y0: row * tileSize,
+			x1: int(math.Min(float64(width), float64((column+1)*tileSize))),
+			y1: int(math.Min(float64(height), float64((row+1)*tileSize))),
+		}
+	}
+
+	tiles := make([]tile, 0, columns*rows)
+	switch order {
+	case Scanline:
+		for row := 0; row < rows; row++ {
+			for column := 0; column < columns; column++ {
+				tiles = append(tiles, newTile(column, row))
+			}
+		}
+	case Spiral:
+		for row := 0; row < rows; row++ {
+			for column := 0; column < columns; column++ {
+				tiles = append(tiles, newTile(column, row))
+			}
+		}
+		// sort by the ring of tiles around the center, then by the angle around it
+		centerX, centerY := float64(columns-1)/2, float64(rows-1)/2
+		ring := func(t tile) float64 {
+			return math.Max(math.Abs(float64(t.x0/tileSize)-centerX), math.Abs(float64(t.y0/tileSize)-centerY))
+		}
+		angle := func(t tile) float64 {
+			return math.Atan2(float64(t.y0/tileSize)-centerY, float64(t.x0/tileSize)-centerX)
+		}
+		sort.SliceStable(tiles, func(i, j int) bool {
+			if ringI, ringJ := math.Round(ring(tiles[i])), math.Round(ring(tiles[j])); ringI != ringJ {
+				return ringI < ringJ
+			}
+			return angle(tiles[i]) < angle(tiles[j])
+		})
+	case Hilbert:
+		// the curve covers a square with a power of 2 side, skip the parts outside of the image
+		n := 1
+		for n < columns || n < rows {
+			n *= 2
+		}
+		for d := 0; d < n*n; d++ {
+			column, row := hilbertPoint(n, d)
+			if column < columns && row < rows {
+				tiles = append(tiles, newTile(column, row))
+			}
+		}
+	default:
+		panic(fmt.Sprintf("No tile order found for %d", order))
+	}
+	return tiles
+}
+
+// point at distance d along a hilbert curve filling an n by n square, see https://en.wikipedia.org/wiki/Hilbert_curve
+func hilbertPoint(n int, d int) (x int, y int) {
+	for s := 1; s < n; s *= 2 {
+		rx := 1 & (d / 2)
+		ry := 1 & (d ^ rx)
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x
+				y = s - 1 - y
+			}
+			x, y = y, x
+		}
+		x += s * rx
+		y += s * ry
+		d /= 4
+	}
+	return x, y
+}
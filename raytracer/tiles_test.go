@@ -0,0 +1,162 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"image"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestImageTilesCoverImage(t *testing.T) {
+	for _, order := range []TileOrder{Scanline, Spiral, Hilbert} {
+		width, height := 70, 45
+		covered := make([]int, width*height)
+		for _, tile := range imageTiles(width, height, 16, order) {
+			for y := tile.y0; y < tile.y1; y++ {
+				for x := tile.x0; x < tile.x1; x++ {
+					covered[y*width+x]++
+				}
+			}
+		}
+		for i, c := range covered {
+			if c != 1 {
+				t.Fatalf("order %d: expected pixel (%d, %d) to be in 1 tile, was in %d", order, i%width, i/width, c)
+			}
+		}
+	}
+
+	// consecutive tiles of a hilbert curve are next to each other
+	tiles := imageTiles(64, 64, 8, Hilbert)
+	for i := 1; i < len(tiles); i++ {
+		if math.Abs(float64(tiles[i].x0-tiles[i-1].x0))+math.Abs(float64(tiles[i].y0-tiles[i-1].y0)) != 8 {
+			t.Fatalf("expected tile %d at (%d, %d) to be next to the previous tile at (%d, %d)", i, tiles[i].x0, tiles[i].y0, tiles[i-1].x0, tiles[i-1].y0)
+		}
+	}
+
+	// spiral starts in the middle
+	tiles = imageTiles(48, 48, 16, Spiral)
+	if tiles[0].x0 != 16 || tiles[0].y0 != 16 {
+		t.Errorf("expected the spiral to start at the center tile, got (%d, %d)", tiles[0].x0, tiles[0].y0)
+	}
+}
+
+func benchmarkScene() (ImageSpec, Scene) {
+	imageSpec := ImageSpec{
+		Width:                           160,
+		Height:                          120,
+		AntiAliasingFactor:              4,
+		RayTracingMaxDepth:              4,
+		SoftShadowMonteCarloRepetitions: 1,
+		WorkerCount:                     4,
+	}
+	scene := Scene{
+		CameraLookFrom: r3.Vec{Y: 2, Z: -6},
+		CameraLookAt:   r3.Vec{},
+		CameraUp:       r3.Vec{Y: 1},
+		CameraFov:      60,
+		Shapes: []Shape{
+			&Sphere{Center: r3.Vec{Y: -100}, Radius: 99, Mat: PhongBlinn{ColorFrac: r3.Vec{X: 0.5, Y: 0.5, Z: 0.5}}},
+			&Sphere{Center: r3.Vec{X: -1.5}, Radius: 1, Mat: Metal{Albedo: r3.Vec{X: 0.8, Y: 0.8, Z: 0.8}}},
+			&Sphere{Center: r3.Vec{}, Radius: 1, Mat: Dielectric{RefractiveIndex: 1.5}},
+			&Sphere{Center: r3.Vec{X: 1.5}, Radius: 1, Mat: PhongBlinn{ColorFrac: r3.Vec{X: 1}, SpecularColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}, SpecHardness: 20}},
+		},
+		Lights: []Light{
+			AmbientLight{ColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}, LightIntensity: 0.1},
+			PointLight{ColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}, Position: r3.Vec{Y: 5, Z: -3}, LightIntensity: 1, SpecularLightIntensity: 1},
+		},
+	}
+	return imageSpec, scene
+}
+
+// the scheduler before tiles, one job and one result sent through channels for every pixel
+func renderPerPixelJobs(imageSpec ImageSpec, scene Scene, bvh *boundingVolumeHierarchy) *image.RGBA {
+	type job struct{ i, j int }
+	type result struct {
+		pixelIdx int
+		color    r3.Vec
+	}
+	cam := scene.camera(float64(imageSpec.Width) / float64(imageSpec.Height))
+	img := image.NewRGBA(image.Rect(0, 0, imageSpec.Width, imageSpec.Height))
+	jobs := make(chan job, imageSpec.Height*imageSpec.Width)
+	results := make(chan result, imageSpec.Height*imageSpec.Width)
+	for w := 0; w < imageSpec.WorkerCount; w++ {
+		go func() {
+			traceFunction := bvh.getTraceFunction(imageSpec.BvhTraversalAlgorithm)
+			for jb := range jobs {
+				c, _ := computePixel(&imageSpec, cam, bvh, traceFunction, &scene.Lights, jb.i, jb.j, newPixelSamples(newSampler(imageSpec.Sampler, imageSpec.AntiAliasingFactor), imageSpec.Seed, jb.i, imageSpec.Height-1-jb.j), 0)
+				results <- result{pixelIdx: ((imageSpec.Height-1-jb.j)*imageSpec.Width + jb.i) * 4, color: c}
+			}
+		}()
+	}
+	for j := imageSpec.Height - 1; j >= 0; j-- {
+		for i := 0; i < imageSpec.Width; i++ {
+			jobs <- job{i: i, j: j}
+		}
+	}
+	close(jobs)
+	for n := 0; n < imageSpec.Width*imageSpec.Height; n++ {
+		r := <-results
+		img.Pix[r.pixelIdx+0] = uint8(math.Min(255, r.color.X*255.99))
+		img.Pix[r.pixelIdx+1] = uint8(math.Min(255, r.color.Y*255.99))
+		img.Pix[r.pixelIdx+2] = uint8(math.Min(255, r.color.Z*255.99))
+		img.Pix[r.pixelIdx+3] = 255
+	}
+	return img
+}
+
+// silences the progress output of renders in tests and benchmarks
+func withoutStdout(tb testing.TB, f func()) {
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}()
+	f()
+}
+
+func benchmarkTiles(b *testing.B, tileSize int, order TileOrder) {
+	imageSpec, scene := benchmarkScene()
+	imageSpec.TileSize = tileSize
+	imageSpec.TileOrder = order
+	var bvh *boundingVolumeHierarchy
+	withoutStdout(b, func() {
+		shapes := scene.renderShapes()
+		bvh = NewBoundingVolumeHierarchy(&shapes)
+	})
+	b.ResetTimer()
+	startTime := time.Now()
+	withoutStdout(b, func() {
+		for n := 0; n < b.N; n++ {
+			traceImage(imageSpec, scene, bvh)
+		}
+	})
+	b.ReportMetric(float64(b.N*imageSpec.Width*imageSpec.Height)/time.Since(startTime).Seconds(), "pixels/s")
+}
+
+func BenchmarkPerPixelJobs(b *testing.B) {
+	imageSpec, scene := benchmarkScene()
+	var bvh *boundingVolumeHierarchy
+	withoutStdout(b, func() {
+		shapes := scene.renderShapes()
+		bvh = NewBoundingVolumeHierarchy(&shapes)
+	})
+	b.ResetTimer()
+	startTime := time.Now()
+	for n := 0; n < b.N; n++ {
+		renderPerPixelJobs(imageSpec, scene, bvh)
+	}
+	b.ReportMetric(float64(b.N*imageSpec.Width*imageSpec.Height)/time.Since(startTime).Seconds(), "pixels/s")
+}
+
+func BenchmarkTiles8Scanline(b *testing.B)  { benchmarkTiles(b, 8, Scanline) }
+func BenchmarkTiles32Scanline(b *testing.B) { benchmarkTiles(b, 32, Scanline) }
+func BenchmarkTiles32Spiral(b *testing.B)   { benchmarkTiles(b, 32, Spiral) }
+func BenchmarkTiles32Hilbert(b *testing.B)  { benchmarkTiles(b, 32, Hilbert) }
+func BenchmarkTiles128Hilbert(b *testing.B) { benchmarkTiles(b, 128, Hilbert) }
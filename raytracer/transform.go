@@ -0,0 +1,233 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+)
+
+// rotation stored as a unit quaternion, the zero value is treated as no rotation
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// wraps a shape with an affine transform, rays are transformed into the space of the wrapped shape when tracing
+// this allows transforms the shapes can't do themselves, eg non-uniform scale turning a sphere into an ellipsoid
+// like the primitives, Rotate turns the shape around the world origin while Scale keeps its centroid in place
+type TransformedShape struct {
+	Shape     Shape
+	transform Matrix4
+	inverse   Matrix4
+}
+
+// transformedSurface maps texture lookups on a world space hit back into the space of the shape that was hit
+type transformedSurface struct {
+	Shape
+	transform Matrix4
+	inverse   Matrix4
+}
+
+func IdentityQuaternion() Quaternion {
+	return Quaternion{W: 1}
+}
+
+// angle is in degrees
+func QuaternionFromAxisAngle(axis r3.Vec, angle float64) Quaternion {
+	sin, cos := math.Sincos(angle * math.Pi / 360.0)
+	a := r3.Unit(axis)
+	return Quaternion{W: cos, X: a.X * sin, Y: a.Y * sin, Z: a.Z * sin}
+}
+
+// rotation vector is in degrees, applied in the same order as RotationMatrix
+func QuaternionFromEuler(rv r3.Vec) Quaternion {
+	qx := QuaternionFromAxisAngle(r3.Vec{X: 1}, rv.X)
+	qy := QuaternionFromAxisAngle(r3.Vec{Y: 1}, rv.Y)
+	qz := QuaternionFromAxisAngle(r3.Vec{Z: 1}, rv.Z)
+	return qy.Multiply(qx.Multiply(qz))
+}
+
+func (q Quaternion) isZero() bool {
+	return q.W == 0 && q.X == 0 && q.Y == 0 && q.Z == 0
+}
+
+func (q Quaternion) orIdentity() Quaternion {
+	if q.isZero() {
+		return IdentityQuaternion()
+	}
+	return q
+}
+
+// returns q * o, meaning rotation o is applied first and then q
+func (q Quaternion) Multiply(o Quaternion) Quaternion {
+	q = q.orIdentity()
+	o = o.orIdentity()
+	return Quaternion{
+		W: q.W*o.W - q.X*o.X - q.Y*o.Y - q.Z*o.Z,
+		X: q.W*o.X + q.X*o.W + q.Y*o.Z - q.Z*o.Y,
+		Y: q.W*o.Y - q.X*o.Z + q.Y*o.W + q.Z*o.X,
+		Z: q.W*o.Z + q.X*o.Y - q.Y*o.X + q.Z*o.W,
+	}
+}
+
+func (q Quaternion) Normalize() Quaternion {
+	q = q.orIdentity()
+	l := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	return Quaternion{W: q.W / l, X: q.X / l, Y: q.Y / l, Z: q.Z / l}
+}
+
+func (q Quaternion) Conjugate() Quaternion {
+	q = q.orIdentity()
+	return Quaternion{W: q.W, X: -q.X, Y: -q.Y, Z: -q.Z}
+}
+
+func (q Quaternion) Rotate(v r3.Vec) r3.Vec {
+	return q.Matrix().TransformVector(v)
+}
+
+func (q Quaternion) Matrix() Matrix4 {
+	q = q.Normalize()
+	return Matrix4{
+		{1 - 2*(q.Y*q.Y+q.Z*q.Z), 2 * (q.X*q.Y - q.Z*q.W), 2 * (q.X*q.Z + q.Y*q.W), 0},
+		{2 * (q.X*q.Y + q.Z*q.W), 1 - 2*(q.X*q.X+q.Z*q.Z), 2 * (q.Y*q.Z - q.X*q.W), 0},
+		{2 * (q.X*q.Z - q.Y*q.W), 2 * (q.Y*q.Z + q.X*q.W), 1 - 2*(q.X*q.X+q.Y*q.Y), 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// spherical linear interpolation, f is between [0, 1]
+func (q Quaternion) Slerp(o Quaternion, f float64) Quaternion {
+	q = q.Normalize()
+	o = o.Normalize()
+	cosTheta := q.W*o.W + q.X*o.X + q.Y*o.Y + q.Z*o.Z
+	// take the shortest path around the sphere
+	if cosTheta < 0 {
+		o = Quaternion{W: -o.W, X: -o.X, Y: -o.Y, Z: -o.Z}
+		cosTheta = -cosTheta
+	}
+	a, b := 1-f, f
+	// fall back to linear interpolation when the rotations are nearly the same
+	if cosTheta < 0.9995 {
+		theta := math.Acos(cosTheta)
+		sinTheta := math.Sin(theta)
+		a = math.Sin((1-f)*theta) / sinTheta
+		b = math.Sin(f*theta) / sinTheta
+	}
+	return Quaternion{
+		W: a*q.W + b*o.W,
+		X: a*q.X + b*o.X,
+		Y: a*q.Y + b*o.Y,
+		Z: a*q.Z + b*o.Z,
+	}.Normalize()
+}
+
+// angle is in degrees
+func RotationAroundAxisMatrix(axis r3.Vec, angle float64) Matrix4 {
+	return QuaternionFromAxisAngle(axis, angle).Matrix()
+}
+
+// applies m with pivot as the origin, eg rotating or scaling a shape around its own center
+func PivotMatrix(m Matrix4, pivot r3.Vec) Matrix4 {
+	return TranslationMatrix(pivot).Multiply(m.Multiply(TranslationMatrix(r3.Scale(-1, pivot))))
+}
+
+// combines the transforms into one, the first transform is applied first
+func ComposeMatrices(transforms ...Matrix4) Matrix4 {
+	res := IdentityMatrix()
+	for _, m := range transforms {
+		res = m.Multiply(res)
+	}
+	return res
+}
+
+func NewTransformedShape(shape Shape, transform Matrix4) *TransformedShape {
+	return &TransformedShape{
+		Shape:     shape,
+		transform: transform,
+		inverse:   transform.Inverse(),
+	}
+}
+
+func (ts *TransformedShape) Transform() Matrix4 {
+	return ts.transform
+}
+
+func (ts *TransformedShape) SetTransform(transform Matrix4) {
+	ts.transform = transform
+	ts.inverse = transform.Inverse()
+}
+
+// applies m on top of the current transform
+func (ts *TransformedShape) ApplyTransform(m Matrix4) {
+	ts.SetTransform(m.Multiply(ts.transform))
+}
+
+func (ts TransformedShape) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	objectRay, scale := transformRay(&ts.inverse, r)
+	hr := ts.Shape.hit(&objectRay, tMin*scale, tMax*scale)
+	if hr.t <= 0 {
+		return hitRecord{t: -1}
+	}
+	return transformHitRecord(r, &hr, scale, &ts.transform, &ts.inverse)
+}
+
+func (ts *TransformedShape) Translate(tv r3.Vec) {
+	ts.ApplyTransform(TranslationMatrix(tv))
+}
+
+// scales around the centroid of the shape
+func (ts *TransformedShape) Scale(c float64) {
+	ts.ScaleNonUniform(r3.Vec{X: c, Y: c, Z: c})
+}
+
+// scales around the centroid of the shape
+func (ts *TransformedShape) ScaleNonUniform(sv r3.Vec) {
+	ts.ApplyTransform(PivotMatrix(ScalingMatrix(sv), ts.centroid()))
+}
+
+// rotates around the world origin, so a shape away from the origin moves, use ApplyTransform with PivotMatrix to turn it in place
+func (ts *TransformedShape) Rotate(rv r3.Vec) {
+	ts.ApplyTransform(RotationMatrix(rv))
+}
+
+func (ts TransformedShape) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	pMin, pMax := ts.Shape.computeSquareBounds()
+	return transformBounds(&ts.transform, pMin, pMax)
+}
+
+func (ts TransformedShape) centroid() r3.Vec {
+	return ts.transform.TransformPoint(ts.Shape.centroid())
+}
+
+func (ts TransformedShape) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	return ts.Shape.textureMap(ts.inverse.TransformPoint(point), transformNormal(&ts.transform, normal))
+}
+
+func (ts TransformedShape) description() string {
+	return fmt.Sprintf(
+		"%s - Shape: %s, Transform: %v",
+		reflect.TypeOf(ts),
+		ts.Shape.description(),
+		ts.transform,
+	)
+}
+
+func (ts transformedSurface) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	return ts.Shape.textureMap(ts.inverse.TransformPoint(point), transformNormal(&ts.transform, normal))
+}
+
+// converts a hit record found by tracing a transformed ray back into the space of the original ray
+func transformHitRecord(r *ray, hr *hitRecord, scale float64, transform *Matrix4, inverse *Matrix4) hitRecord {
+	t := hr.t / scale
+	return hitRecord{
+		t:      t,
+		p:      r.PointAtT(t),
+		normal: transformNormal(inverse, hr.normal),
+		shape: &transformedSurface{
+			Shape:     hr.shape,
+			transform: *transform,
+			inverse:   *inverse,
+		},
+		material: hr.material,
+	}
+}
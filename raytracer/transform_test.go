@@ -0,0 +1,74 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestQuaternionMatchesRotationMatrix(t *testing.T) {
+	rv := r3.Vec{X: 30, Y: -45, Z: 60}
+	p := r3.Vec{X: 1, Y: 2, Z: 3}
+	expected := rotatePoint(p, rv)
+	fromMatrix := RotationMatrix(rv).TransformPoint(p)
+	fromQuaternion := QuaternionFromEuler(rv).Rotate(p)
+	if r3.Norm(r3.Sub(expected, fromMatrix)) > 1e-9 {
+		t.Errorf("rotation matrix gave %v, expected %v", fromMatrix, expected)
+	}
+	if r3.Norm(r3.Sub(expected, fromQuaternion)) > 1e-9 {
+		t.Errorf("quaternion gave %v, expected %v", fromQuaternion, expected)
+	}
+
+	m := ComposeMatrices(ScalingMatrix(r3.Vec{X: 1, Y: 2, Z: 3}), RotationMatrix(rv), TranslationMatrix(r3.Vec{X: 4, Y: 5, Z: 6}))
+	roundTrip := m.Inverse().TransformPoint(m.TransformPoint(p))
+	if r3.Norm(r3.Sub(p, roundTrip)) > 1e-9 {
+		t.Errorf("inverse transform gave %v, expected %v", roundTrip, p)
+	}
+}
+
+func TestTransformedShapeEllipsoid(t *testing.T) {
+	ellipsoid := NewTransformedShape(&Sphere{Center: r3.Vec{}, Radius: 1, Mat: Standard{}}, IdentityMatrix())
+	ellipsoid.ScaleNonUniform(r3.Vec{X: 2, Y: 1, Z: 1})
+	ellipsoid.Translate(r3.Vec{X: 0, Y: 0, Z: 5})
+
+	alongX := ray{p: r3.Vec{X: -10, Y: 0, Z: 5}, normalizedDirection: r3.Vec{X: 1, Y: 0, Z: 0}}
+	if hr := ellipsoid.hit(&alongX, 0, math.MaxFloat64); math.Abs(hr.t-8) > 1e-9 {
+		t.Errorf("expected ellipsoid hit at t=8 along the x axis, got t=%f", hr.t)
+	}
+	alongY := ray{p: r3.Vec{X: 0, Y: -10, Z: 5}, normalizedDirection: r3.Vec{X: 0, Y: 1, Z: 0}}
+	if hr := ellipsoid.hit(&alongY, 0, math.MaxFloat64); math.Abs(hr.t-9) > 1e-9 {
+		t.Errorf("expected ellipsoid hit at t=9 along the y axis, got t=%f", hr.t)
+	}
+
+	lowest, highest := ellipsoid.computeSquareBounds()
+	if r3.Norm(r3.Sub(lowest, r3.Vec{X: -2, Y: -1, Z: 4})) > 1e-9 || r3.Norm(r3.Sub(highest, r3.Vec{X: 2, Y: 1, Z: 6})) > 1e-9 {
+		t.Errorf("unexpected ellipsoid bounds %v %v", lowest, highest)
+	}
+
+	// Rotate turns it around the world origin, a pivot on the centroid turns it in place
+	ellipsoid.Rotate(r3.Vec{Y: 90})
+	if c := ellipsoid.centroid(); math.Abs(c.Z) > 1e-9 || math.Abs(r3.Norm(c)-5) > 1e-9 {
+		t.Errorf("expected the rotated ellipsoid to be turned around the origin, got centroid %v", c)
+	}
+	before := ellipsoid.centroid()
+	ellipsoid.ApplyTransform(PivotMatrix(RotationMatrix(r3.Vec{Y: 90}), before))
+	if r3.Norm(r3.Sub(ellipsoid.centroid(), before)) > 1e-9 {
+		t.Errorf("expected the ellipsoid to be turned in place, centroid moved from %v to %v", before, ellipsoid.centroid())
+	}
+}
+
+func TestTrianglePlaneScalesAroundCentroid(t *testing.T) {
+	tr := TrianglePlane{
+		PointA: r3.Vec{X: 10, Y: 0, Z: 0},
+		PointB: r3.Vec{X: 12, Y: 0, Z: 0},
+		PointC: r3.Vec{X: 11, Y: 3, Z: 0},
+	}
+	before := tr.centroid()
+	tr.Scale(2)
+	if r3.Norm(r3.Sub(before, tr.centroid())) > 1e-9 {
+		t.Errorf("centroid moved from %v to %v when scaling", before, tr.centroid())
+	}
+	if math.Abs(r3.Norm(r3.Sub(tr.PointB, tr.PointA))-4) > 1e-9 {
+		t.Errorf("expected scaled edge length of 4, got %f", r3.Norm(r3.Sub(tr.PointB, tr.PointA)))
+	}
+}
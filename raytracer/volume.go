@@ -0,0 +1,178 @@
+package raytracer
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"reflect"
+)
+
+// participating medium (eg fog, smoke) with the same density everywhere inside of Boundary
+// rays travelling through it are randomly scattered towards the lights, or absorbed, based on the distance travelled
+type ConstantMedium struct {
+	Boundary   Solid   // closed shape the medium fills, nil fills all of space
+	Absorption float64 // amount of light absorbed per world unit
+	Scattering float64 // amount of light scattered per world unit
+	ColorFrac  r3.Vec  // tint of the scattered light
+	Anisotropy float64 // henyey-greenstein g between (-1, 1), 0 scatters evenly and positive values scatter forwards
+}
+
+// medium filling the whole scene, eg haze making spot light beams visible
+type Atmosphere struct {
+	Absorption float64
+	Scattering float64
+	ColorFrac  r3.Vec
+	Anisotropy float64
+}
+
+// material for a point inside of a medium where a ray scatters, lit directly by the lights
+type volumeScatter struct {
+	albedo     r3.Vec
+	anisotropy float64
+}
+
+func (m ConstantMedium) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	extinction := m.Absorption + m.Scattering
+	if extinction <= 0 {
+		return hitRecord{t: -1}
+	}
+
+	segments := [][2]float64{{tMin, tMax}}
+	if m.Boundary != nil {
+		segments = segments[:0]
+		for _, interval := range m.Boundary.intervals(r) {
+			segments = append(segments, [2]float64{math.Max(tMin, interval.enter.t), math.Min(tMax, interval.exit.t)})
+		}
+	}
+
+	for _, segment := range segments {
+		if segment[0] >= segment[1] {
+			continue
+		}
+		// free flight distance, the chance of travelling a distance without scattering or absorbing decays exponentially
+		t := segment[0] - math.Log(1-r.samples.random())/extinction
+		if t < segment[1] {
+			return m.scatterRecord(r, t)
+		}
+	}
+	return hitRecord{t: -1}
+}
+
+func (m ConstantMedium) scatterRecord(r *ray, t float64) hitRecord {
+	albedo := m.Scattering / (m.Absorption + m.Scattering)
+	return hitRecord{
+		t:      t,
+		p:      r.PointAtT(t),
+		normal: r3.Scale(-1, r.normalizedDirection),
+		shape:  &m,
+		material: volumeScatter{
+			albedo:     r3.Scale(albedo, m.ColorFrac),
+			anisotropy: m.Anisotropy,
+		},
+	}
+}
+
+func (m *ConstantMedium) Translate(tv r3.Vec) {
+	if m.Boundary != nil {
+		m.Boundary.Translate(tv)
+	}
+}
+
+func (m *ConstantMedium) Scale(c float64) {
+	if m.Boundary != nil {
+		m.Boundary.Scale(c)
+	}
+}
+
+func (m *ConstantMedium) Rotate(rv r3.Vec) {
+	if m.Boundary != nil {
+		m.Boundary.Rotate(rv)
+	}
+}
+
+func (m ConstantMedium) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	if m.Boundary == nil {
+		return r3.Vec{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}, r3.Vec{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+	}
+	return m.Boundary.computeSquareBounds()
+}
+
+func (m ConstantMedium) centroid() r3.Vec {
+	if m.Boundary == nil {
+		return r3.Vec{}
+	}
+	return m.Boundary.centroid()
+}
+
+// media have no surface to put a texture on
+func (m ConstantMedium) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	return 0, 0
+}
+
+func (m ConstantMedium) description() string {
+	boundary := "everywhere"
+	if m.Boundary != nil {
+		boundary = m.Boundary.description()
+	}
+	return fmt.Sprintf(
+		"%s - Absorption: %f, Scattering: %f, Anisotropy: %f, Boundary: (%s)",
+		reflect.TypeOf(m),
+		m.Absorption,
+		m.Scattering,
+		m.Anisotropy,
+		boundary,
+	)
+}
+
+func (a Atmosphere) medium() *ConstantMedium {
+	return &ConstantMedium{
+		Absorption: a.Absorption,
+		Scattering: a.Scattering,
+		ColorFrac:  a.ColorFrac,
+		Anisotropy: a.Anisotropy,
+	}
+}
+
+func (v volumeScatter) albedoColor(hitRecord *hitRecord) r3.Vec {
+	return v.albedo
+}
+
+// single scattering, light reaching the point directly from each light is scattered towards the ray origin
+func (v volumeScatter) scatter(is *ImageSpec, r *ray, hitRecord *hitRecord, traceFunction func(r *ray, tMin float64) (hit bool, record *hitRecord), lights *[]Light) (shouldTrace bool, attenuation r3.Vec, scattered ray, color r3.Vec) {
+	c := r3.Vec{}
+	for _, light := range *lights {
+		lightColor := light.getColorFrac()
+		if light.hasPosition() {
+			monteCarloRepetitions := is.SoftShadowMonteCarloRepetitions
+			for i := 0; i < monteCarloRepetitions; i++ {
+				hitPoint := hitRecord.p
+				monteCarloVariance := r3.Scale(softShadowMonteCarloMaxLengthDeviation, r.samples.inUnitSphere())
+				if light.isPointVisible(&hitPoint, r, traceFunction, &monteCarloVariance) {
+					lightToPoint := r3.Sub(*light.getPosition(), hitPoint)
+					lightDirection := r3.Unit(lightToPoint)
+					lightDecay := light.getInverseSquareLawDecayFactor() * r3.Dot(lightToPoint, lightToPoint)
+					if lightDecay <= 1 {
+						lightDecay = 1
+					}
+
+					// scaled so that scattering evenly in all directions matches a diffuse surface facing the light
+					phase := 4 * math.Pi * henyeyGreenstein(r3.Dot(r.normalizedDirection, lightDirection), v.anisotropy)
+					intensity := phase * light.getLightIntensity() / lightDecay / float64(monteCarloRepetitions)
+					c = r3.Add(c, r3.Scale(intensity, r3.Vec{X: v.albedo.X * lightColor.X, Y: v.albedo.Y * lightColor.Y, Z: v.albedo.Z * lightColor.Z}))
+				}
+			}
+		} else {
+			c = r3.Add(c, r3.Scale(light.getLightIntensity(), r3.Vec{X: v.albedo.X * lightColor.X, Y: v.albedo.Y * lightColor.Y, Z: v.albedo.Z * lightColor.Z}))
+		}
+	}
+	c.X = math.Min(1.0, c.X)
+	c.Y = math.Min(1.0, c.Y)
+	c.Z = math.Min(1.0, c.Z)
+	return false, r3.Vec{}, ray{}, c
+}
+
+// phase function for the fraction of light scattered at an angle, cosTheta of 1 means continuing in the same direction
+func henyeyGreenstein(cosTheta float64, g float64) float64 {
+	denom := 1 + g*g - 2*g*cosTheta
+	return (1 - g*g) / (4 * math.Pi * denom * math.Sqrt(denom))
+}
@@ -0,0 +1,102 @@
+package raytracer
+
+import (
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConstantMediumTransmittance(t *testing.T) {
+	// slab 2 thick along z, rays straight through it
+	medium := ConstantMedium{
+		Boundary:   &Box{Min: r3.Vec{X: -10, Y: -10, Z: 0}, Max: r3.Vec{X: 10, Y: 10, Z: 2}},
+		Absorption: 0.2,
+		Scattering: 0.3,
+		ColorFrac:  r3.Vec{X: 1, Y: 1, Z: 1},
+	}
+	sampler := newSampler(UniformRandom, 1)
+	rays := 20000
+	passed := 0
+	for i := 0; i < rays; i++ {
+		samples := newPixelSamples(sampler, 1, i, 0)
+		samples.startSample(0)
+		r := ray{p: r3.Vec{Z: -1}, normalizedDirection: r3.Vec{Z: 1}, samples: samples}
+		if hr := medium.hit(&r, 0, math.MaxFloat64); hr.t < 0 {
+			passed++
+		} else if hr.p.Z < 0 || hr.p.Z > 2 {
+			t.Fatalf("expected the ray to scatter inside of the slab, got %v", hr.p)
+		}
+	}
+	expected := math.Exp(-(0.2 + 0.3) * 2)
+	if transmittance := float64(passed) / float64(rays); math.Abs(transmittance-expected) > 0.01 {
+		t.Errorf("expected a transmittance of %f, got %f", expected, transmittance)
+	}
+}
+
+func TestHenyeyGreensteinAnisotropy(t *testing.T) {
+	// directions sampled evenly over the sphere and weighted by the phase function, the mean cosine is g
+	random := rand.New(rand.NewSource(1))
+	for _, g := range []float64{-0.5, 0, 0.3, 0.8} {
+		n := 200000
+		total, meanCos := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			cosTheta := 2*random.Float64() - 1
+			weight := 4 * math.Pi * henyeyGreenstein(cosTheta, g)
+			total += weight
+			meanCos += weight * cosTheta
+		}
+		if math.Abs(total/float64(n)-1) > 0.05 {
+			t.Errorf("g %f: expected the phase function to integrate to 1, got %f", g, total/float64(n))
+		}
+		if math.Abs(meanCos/float64(n)-g) > 0.05 {
+			t.Errorf("g %f: expected the mean cosine to be g, got %f", g, meanCos/float64(n))
+		}
+	}
+}
+
+func TestAtmosphereLightsSpotLightBeam(t *testing.T) {
+	imageSpec := ImageSpec{
+		Width:                           40,
+		Height:                          30,
+		AntiAliasingFactor:              16,
+		RayTracingMaxDepth:              4,
+		SoftShadowMonteCarloRepetitions: 1,
+		WorkerCount:                     4,
+	}
+	// beam straight down through the middle of the image, nothing else in the scene
+	scene := Scene{
+		CameraLookFrom: r3.Vec{Z: -6},
+		CameraLookAt:   r3.Vec{},
+		CameraUp:       r3.Vec{Y: 1},
+		CameraFov:      60,
+		Lights: []Light{
+			SpotLight{ColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}, Position: r3.Vec{Y: 4}, LookAt: r3.Vec{}, Angle: 10, LightIntensity: 1},
+		},
+		Atmosphere: &Atmosphere{Scattering: 0.2, ColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}},
+	}
+	var fb *Framebuffer
+	withoutStdout(t, func() { fb = RenderFramebuffer(imageSpec, scene) })
+	// columns down the middle against columns at the side, away from the beam
+	beam, outside := 0.0, 0.0
+	for y := 0; y < fb.Height; y++ {
+		for x := 18; x < 22; x++ {
+			beam += fb.At(x, y).X
+		}
+		for x := 0; x < 4; x++ {
+			outside += fb.At(x, y).X
+		}
+	}
+	if beam < 0.5 {
+		t.Errorf("expected the haze in the beam to be lit, got a total of %f", beam)
+	}
+	if outside != 0 {
+		t.Errorf("expected the haze outside of the beam to be dark, got a total of %f", outside)
+	}
+
+	scene.Atmosphere = nil
+	withoutStdout(t, func() { fb = RenderFramebuffer(imageSpec, scene) })
+	if c := fb.At(20, 15); c != (r3.Vec{}) {
+		t.Errorf("expected the beam to be invisible without haze, got %v", c)
+	}
+}
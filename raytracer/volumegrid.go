@@ -0,0 +1,480 @@
+package raytracer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"gonum.org/v1/gonum/spatial/r3"
+	"io"
+	"math"
+	"reflect"
+)
+
+const denseGridMagic = "RTDG"
+const sparseGridMagic = "RTSG"
+const sparseGridBlockSize = 8       // voxels along each side of a block, same as the leaf nodes of OpenVDB
+const maxDenseGridVoxels = 1 << 28  // largest dense grid that is read, 1GB of densities
+const maxSparseGridBlocks = 1 << 19 // largest sparse grid that is read, 1GB of densities
+
+// voxel grid of densities, sampled with trilinear interpolation between the centers of the voxels
+type DensityGrid interface {
+	density(p r3.Vec) float64
+	maxDensity() float64
+	bounds() (lowest r3.Vec, highest r3.Vec)
+	translate(tv r3.Vec)
+	scale(c float64) // around the center of the grid
+}
+
+// every voxel stored in one array, x changes fastest then y then z
+type DenseGrid struct {
+	Min    r3.Vec
+	Max    r3.Vec
+	nx     int
+	ny     int
+	nz     int
+	values []float32
+	max    float64
+}
+
+// only blocks of 8x8x8 voxels containing density are stored, everything else is empty
+type SparseGrid struct {
+	Origin    r3.Vec // corner of voxel (0, 0, 0)
+	VoxelSize float64
+	blocks    map[[3]int32]*sparseGridBlock
+	max       float64
+}
+
+type sparseGridBlock [sparseGridBlockSize * sparseGridBlockSize * sparseGridBlockSize]float32
+
+// medium with a density that changes through space, eg smoke from a simulation cache
+// the density of the grid scales how much light is absorbed and scattered at each point
+// grids are axis aligned, rotating the medium turns the rays into the grid instead, like Box does
+type HeterogeneousMedium struct {
+	Grid        DensityGrid
+	Absorption  float64 // amount of light absorbed per world unit at a density of 1
+	Scattering  float64 // amount of light scattered per world unit at a density of 1
+	ColorFrac   r3.Vec
+	Anisotropy  float64    // henyey-greenstein g between (-1, 1)
+	Orientation Quaternion // around the center of the grid, set by Rotate, the zero value keeps it axis aligned
+}
+
+func (m HeterogeneousMedium) hit(r *ray, tMin float64, tMax float64) hitRecord {
+	extinction := m.Absorption + m.Scattering
+	majorant := extinction * m.Grid.maxDensity()
+	// delta tracking never ends with a NaN or infinite majorant
+	if !(majorant > 0) || math.IsInf(majorant, 1) {
+		return hitRecord{t: -1}
+	}
+	pMin, pMax := m.Grid.bounds()
+	// rotations keep distances, so the ray in the space of the grid has the same t
+	gridRay := *r
+	if m.rotated() {
+		center := r3.Scale(0.5, r3.Add(pMin, pMax))
+		toGrid := m.Orientation.Conjugate()
+		gridRay.p = r3.Add(center, toGrid.Rotate(r3.Sub(r.p, center)))
+		gridRay.normalizedDirection = toGrid.Rotate(r.normalizedDirection)
+	}
+	tNear, tFar, ok := intersectSlabs(gridRay.p, gridRay.normalizedDirection, pMin, pMax)
+	if !ok {
+		return hitRecord{t: -1}
+	}
+
+	// delta tracking, take steps as if the whole grid had the maximum density
+	// and accept a step as a real collision with the probability of the actual density compared to the maximum
+	t := math.Max(tMin, tNear)
+	tEnd := math.Min(tMax, tFar)
+	for {
+		t -= math.Log(1-r.samples.random()) / majorant
+		if t >= tEnd {
+			return hitRecord{t: -1}
+		}
+		if r.samples.random()*majorant < extinction*m.Grid.density(gridRay.PointAtT(t)) {
+			medium := ConstantMedium{
+				Absorption: m.Absorption,
+				Scattering: m.Scattering,
+				ColorFrac:  m.ColorFrac,
+				Anisotropy: m.Anisotropy,
+			}
+			hr := medium.scatterRecord(r, t)
+			hr.shape = &m
+			return hr
+		}
+	}
+}
+
+func (m *HeterogeneousMedium) Translate(tv r3.Vec) {
+	m.Grid.translate(tv)
+}
+
+// scales around the center of the grid, the densities stay the same
+func (m *HeterogeneousMedium) Scale(c float64) {
+	m.Grid.scale(c)
+}
+
+// like a Box, the center is rotated around the origin and the grid is turned by Orientation
+func (m *HeterogeneousMedium) Rotate(rv r3.Vec) {
+	center := m.centroid()
+	m.Grid.translate(r3.Sub(rotatePoint(center, rv), center))
+	m.Orientation = QuaternionFromEuler(rv).Multiply(m.Orientation)
+}
+
+func (m HeterogeneousMedium) rotated() bool {
+	return m.Orientation.orIdentity() != IdentityQuaternion()
+}
+
+func (m HeterogeneousMedium) computeSquareBounds() (lowest r3.Vec, highest r3.Vec) {
+	pMin, pMax := m.Grid.bounds()
+	if m.rotated() {
+		rotation := PivotMatrix(m.Orientation.Matrix(), r3.Scale(0.5, r3.Add(pMin, pMax)))
+		return transformBounds(&rotation, pMin, pMax)
+	}
+	return pMin, pMax
+}
+
+func (m HeterogeneousMedium) centroid() r3.Vec {
+	lowest, highest := m.Grid.bounds()
+	return r3.Scale(0.5, r3.Add(lowest, highest))
+}
+
+func (m HeterogeneousMedium) textureMap(point r3.Vec, normal r3.Vec) (u, v float64) {
+	return 0, 0
+}
+
+func (m HeterogeneousMedium) description() string {
+	lowest, highest := m.Grid.bounds()
+	return fmt.Sprintf(
+		"%s - Grid: %s, Bounds: %v %v, Absorption: %f, Scattering: %f",
+		reflect.TypeOf(m),
+		reflect.TypeOf(m.Grid),
+		lowest,
+		highest,
+		m.Absorption,
+		m.Scattering,
+	)
+}
+
+// values holds nx*ny*nz densities with x changing fastest, the voxels are spread evenly between min and max
+func NewDenseGrid(min r3.Vec, max r3.Vec, nx int, ny int, nz int, values []float32) *DenseGrid {
+	if len(values) != nx*ny*nz {
+		panic(fmt.Sprintf("Expected %d values for a %dx%dx%d grid, got %d", nx*ny*nz, nx, ny, nz, len(values)))
+	}
+	if err := checkDensities(values); err != nil {
+		panic(err.Error())
+	}
+	g := DenseGrid{Min: min, Max: max, nx: nx, ny: ny, nz: nz, values: values}
+	for _, v := range values {
+		g.max = math.Max(g.max, float64(v))
+	}
+	return &g
+}
+
+// densities have to be finite and not negative, a NaN or infinite maximum density stops delta tracking from ending
+func checkDensities(values []float32) error {
+	for i, v := range values {
+		if !(v >= 0) || math.IsInf(float64(v), 1) {
+			return fmt.Errorf("density %f of voxel %d is not a finite density of 0 or more", v, i)
+		}
+	}
+	return nil
+}
+
+func checkVoxelSize(voxelSize float64) error {
+	if !(voxelSize > 0) || math.IsInf(voxelSize, 1) {
+		return fmt.Errorf("voxel size %f is not a finite size larger than 0", voxelSize)
+	}
+	return nil
+}
+
+func (g *DenseGrid) density(p r3.Vec) float64 {
+	size := r3.Sub(g.Max, g.Min)
+	return trilinear(
+		(p.X-g.Min.X)/size.X*float64(g.nx)-0.5,
+		(p.Y-g.Min.Y)/size.Y*float64(g.ny)-0.5,
+		(p.Z-g.Min.Z)/size.Z*float64(g.nz)-0.5,
+		g.voxel,
+	)
+}
+
+// clamped to the edge, so the density doesn't fade out in the outer half of the outer voxels
+func (g *DenseGrid) voxel(i, j, k int) float64 {
+	clamp := func(x int, n int) int {
+		if x < 0 {
+			return 0
+		}
+		if x >= n {
+			return n - 1
+		}
+		return x
+	}
+	return float64(g.values[(clamp(k, g.nz)*g.ny+clamp(j, g.ny))*g.nx+clamp(i, g.nx)])
+}
+
+func (g *DenseGrid) maxDensity() float64 {
+	return g.max
+}
+
+func (g *DenseGrid) bounds() (lowest r3.Vec, highest r3.Vec) {
+	return g.Min, g.Max
+}
+
+func (g *DenseGrid) translate(tv r3.Vec) {
+	g.Min = r3.Add(tv, g.Min)
+	g.Max = r3.Add(tv, g.Max)
+}
+
+func (g *DenseGrid) scale(c float64) {
+	center := r3.Scale(0.5, r3.Add(g.Min, g.Max))
+	g.Min = r3.Add(center, r3.Scale(c, r3.Sub(g.Min, center)))
+	g.Max = r3.Add(center, r3.Scale(c, r3.Sub(g.Max, center)))
+}
+
+func (g *SparseGrid) density(p r3.Vec) float64 {
+	return trilinear(
+		(p.X-g.Origin.X)/g.VoxelSize-0.5,
+		(p.Y-g.Origin.Y)/g.VoxelSize-0.5,
+		(p.Z-g.Origin.Z)/g.VoxelSize-0.5,
+		g.voxel,
+	)
+}
+
+func (g *SparseGrid) voxel(i, j, k int) float64 {
+	key, index := sparseGridIndex(i, j, k)
+	block, ok := g.blocks[key]
+	if !ok {
+		return 0
+	}
+	return float64(block[index])
+}
+
+// the block a voxel is in and its index inside of the block
+func sparseGridIndex(i, j, k int) (key [3]int32, index int) {
+	bi, bj, bk := floorDiv(i, sparseGridBlockSize), floorDiv(j, sparseGridBlockSize), floorDiv(k, sparseGridBlockSize)
+	i -= bi * sparseGridBlockSize
+	j -= bj * sparseGridBlockSize
+	k -= bk * sparseGridBlockSize
+	return [3]int32{int32(bi), int32(bj), int32(bk)}, (k*sparseGridBlockSize+j)*sparseGridBlockSize + i
+}
+
+func (g *SparseGrid) maxDensity() float64 {
+	return g.max
+}
+
+// bounds of the blocks that are stored
+func (g *SparseGrid) bounds() (lowest r3.Vec, highest r3.Vec) {
+	lowest = r3.Vec{X: math.MaxFloat64, Y: math.MaxFloat64, Z: math.MaxFloat64}
+	highest = r3.Vec{X: -math.MaxFloat64, Y: -math.MaxFloat64, Z: -math.MaxFloat64}
+	blockSize := g.VoxelSize * sparseGridBlockSize
+	for key := range g.blocks {
+		blockMin := r3.Add(g.Origin, r3.Scale(blockSize, r3.Vec{X: float64(key[0]), Y: float64(key[1]), Z: float64(key[2])}))
+		blockMax := r3.Add(blockMin, r3.Vec{X: blockSize, Y: blockSize, Z: blockSize})
+		lowest = r3.Vec{X: math.Min(lowest.X, blockMin.X), Y: math.Min(lowest.Y, blockMin.Y), Z: math.Min(lowest.Z, blockMin.Z)}
+		highest = r3.Vec{X: math.Max(highest.X, blockMax.X), Y: math.Max(highest.Y, blockMax.Y), Z: math.Max(highest.Z, blockMax.Z)}
+	}
+	return lowest, highest
+}
+
+func (g *SparseGrid) translate(tv r3.Vec) {
+	g.Origin = r3.Add(tv, g.Origin)
+}
+
+// the voxels grow around the center of the blocks that are stored
+func (g *SparseGrid) scale(c float64) {
+	if len(g.blocks) == 0 {
+		g.VoxelSize *= c
+		return
+	}
+	lowest, highest := g.bounds()
+	center := r3.Scale(0.5, r3.Add(lowest, highest))
+	g.Origin = r3.Add(center, r3.Scale(c, r3.Sub(g.Origin, center)))
+	g.VoxelSize *= c
+}
+
+func NewSparseGrid(origin r3.Vec, voxelSize float64) *SparseGrid {
+	if err := checkVoxelSize(voxelSize); err != nil {
+		panic(err.Error())
+	}
+	return &SparseGrid{Origin: origin, VoxelSize: voxelSize, blocks: make(map[[3]int32]*sparseGridBlock)}
+}
+
+// sets the density of a voxel, allocating its block if needed
+func (g *SparseGrid) SetVoxel(i, j, k int, density float32) {
+	if err := checkDensities([]float32{density}); err != nil {
+		panic(err.Error())
+	}
+	key, index := sparseGridIndex(i, j, k)
+	block, ok := g.blocks[key]
+	if !ok {
+		block = &sparseGridBlock{}
+		g.blocks[key] = block
+	}
+	block[index] = density
+	// lowering a voxel leaves the max too high, which only costs some extra delta tracking steps
+	g.max = math.Max(g.max, float64(density))
+}
+
+// reads a dense grid, stored little endian as:
+// "RTDG", nx, ny, nz as uint32, min and max corners as 6 float32, then nx*ny*nz float32 densities with x changing fastest
+func LoadDenseGrid(file io.Reader) (*DenseGrid, error) {
+	if err := readMagic(file, denseGridMagic); err != nil {
+		return nil, err
+	}
+	var header struct {
+		Nx, Ny, Nz uint32
+		Min, Max   [3]float32
+	}
+	if err := binary.Read(file, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	voxels := uint64(header.Nx) * uint64(header.Ny) * uint64(header.Nz)
+	if voxels > maxDenseGridVoxels {
+		return nil, fmt.Errorf("dense grid of %dx%dx%d voxels is larger than the %d voxels that can be read", header.Nx, header.Ny, header.Nz, maxDenseGridVoxels)
+	}
+	// read in chunks, so a header claiming more voxels than the file has fails when they run out, not by allocating them
+	values := []float32{}
+	chunk := make([]float32, 1<<16)
+	for remaining := int(voxels); remaining > 0; remaining -= len(chunk) {
+		if remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+		if err := binary.Read(file, binary.LittleEndian, chunk); err != nil {
+			return nil, err
+		}
+		values = append(values, chunk...)
+	}
+	if err := checkDensities(values); err != nil {
+		return nil, err
+	}
+	min := r3.Vec{X: float64(header.Min[0]), Y: float64(header.Min[1]), Z: float64(header.Min[2])}
+	max := r3.Vec{X: float64(header.Max[0]), Y: float64(header.Max[1]), Z: float64(header.Max[2])}
+	return NewDenseGrid(min, max, int(header.Nx), int(header.Ny), int(header.Nz), values), nil
+}
+
+func WriteDenseGrid(file io.Writer, g *DenseGrid) error {
+	if _, err := file.Write([]byte(denseGridMagic)); err != nil {
+		return err
+	}
+	header := struct {
+		Nx, Ny, Nz uint32
+		Min, Max   [3]float32
+	}{
+		Nx:  uint32(g.nx),
+		Ny:  uint32(g.ny),
+		Nz:  uint32(g.nz),
+		Min: [3]float32{float32(g.Min.X), float32(g.Min.Y), float32(g.Min.Z)},
+		Max: [3]float32{float32(g.Max.X), float32(g.Max.Y), float32(g.Max.Z)},
+	}
+	if err := binary.Write(file, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(file, binary.LittleEndian, g.values)
+}
+
+// reads a sparse grid laid out like the leaf nodes of an OpenVDB tree, stored little endian as:
+// "RTSG", origin as 3 float32, voxel size as float32, block count as uint32,
+// then for each block its index as 3 int32 followed by 8*8*8 float32 densities with x changing fastest
+// this is not the .vdb file format, convert VDB caches with a tool that can read them (eg by dumping their leaf nodes)
+func LoadSparseGrid(file io.Reader) (*SparseGrid, error) {
+	if err := readMagic(file, sparseGridMagic); err != nil {
+		return nil, err
+	}
+	var header struct {
+		Origin     [3]float32
+		VoxelSize  float32
+		BlockCount uint32
+	}
+	if err := binary.Read(file, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if err := checkVoxelSize(float64(header.VoxelSize)); err != nil {
+		return nil, err
+	}
+	if header.BlockCount > maxSparseGridBlocks {
+		return nil, fmt.Errorf("sparse grid of %d blocks is larger than the %d blocks that can be read", header.BlockCount, maxSparseGridBlocks)
+	}
+	// blocks are allocated as they are read, so a block count larger than the file has fails when they run out
+	g := NewSparseGrid(r3.Vec{X: float64(header.Origin[0]), Y: float64(header.Origin[1]), Z: float64(header.Origin[2])}, float64(header.VoxelSize))
+	for i := uint32(0); i < header.BlockCount; i++ {
+		var key [3]int32
+		if err := binary.Read(file, binary.LittleEndian, &key); err != nil {
+			return nil, err
+		}
+		block := sparseGridBlock{}
+		if err := binary.Read(file, binary.LittleEndian, &block); err != nil {
+			return nil, err
+		}
+		if err := checkDensities(block[:]); err != nil {
+			return nil, err
+		}
+		for _, v := range block {
+			g.max = math.Max(g.max, float64(v))
+		}
+		g.blocks[key] = &block
+	}
+	return g, nil
+}
+
+func WriteSparseGrid(file io.Writer, g *SparseGrid) error {
+	if _, err := file.Write([]byte(sparseGridMagic)); err != nil {
+		return err
+	}
+	header := struct {
+		Origin     [3]float32
+		VoxelSize  float32
+		BlockCount uint32
+	}{
+		Origin:     [3]float32{float32(g.Origin.X), float32(g.Origin.Y), float32(g.Origin.Z)},
+		VoxelSize:  float32(g.VoxelSize),
+		BlockCount: uint32(len(g.blocks)),
+	}
+	if err := binary.Write(file, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	for key, block := range g.blocks {
+		if err := binary.Write(file, binary.LittleEndian, key); err != nil {
+			return err
+		}
+		if err := binary.Write(file, binary.LittleEndian, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMagic(file io.Reader, magic string) error {
+	buf := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return err
+	}
+	if string(buf) != magic {
+		return fmt.Errorf("expected file to start with %q, but found %q", magic, string(buf))
+	}
+	return nil
+}
+
+// interpolates between the 8 voxels around a point given in voxel coordinates, where voxel centers are on integers
+func trilinear(x, y, z float64, voxel func(i, j, k int) float64) float64 {
+	i, j, k := int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z))
+	fx, fy, fz := x-float64(i), y-float64(j), z-float64(k)
+	lerp := func(a, b, f float64) float64 {
+		return a + (b-a)*f
+	}
+	return lerp(
+		lerp(
+			lerp(voxel(i, j, k), voxel(i+1, j, k), fx),
+			lerp(voxel(i, j+1, k), voxel(i+1, j+1, k), fx),
+			fy,
+		),
+		lerp(
+			lerp(voxel(i, j, k+1), voxel(i+1, j, k+1), fx),
+			lerp(voxel(i, j+1, k+1), voxel(i+1, j+1, k+1), fx),
+			fy,
+		),
+		fz,
+	)
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
@@ -0,0 +1,146 @@
+package raytracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gonum.org/v1/gonum/spatial/r3"
+	"math"
+	"testing"
+)
+
+func TestVolumeGrids(t *testing.T) {
+	// 2x1x1 dense grid with densities 0 and 1, halfway between the voxel centers the density is 0.5
+	dense := NewDenseGrid(r3.Vec{}, r3.Vec{X: 2, Y: 1, Z: 1}, 2, 1, 1, []float32{0, 1})
+	sparse := NewSparseGrid(r3.Vec{}, 1)
+	sparse.SetVoxel(0, 0, 0, 0)
+	sparse.SetVoxel(1, 0, 0, 1)
+
+	var denseFile, sparseFile bytes.Buffer
+	if err := WriteDenseGrid(&denseFile, dense); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSparseGrid(&sparseFile, sparse); err != nil {
+		t.Fatal(err)
+	}
+	loadedDense, err := LoadDenseGrid(&denseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedSparse, err := LoadSparseGrid(&sparseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// headers claiming more voxels than the file has fail without allocating them
+	for _, header := range []struct{ Nx, Ny, Nz uint32 }{{1 << 20, 1 << 20, 1 << 20}, {1 << 10, 1 << 10, 1 << 8}} {
+		var file bytes.Buffer
+		file.WriteString(denseGridMagic)
+		binary.Write(&file, binary.LittleEndian, header)
+		binary.Write(&file, binary.LittleEndian, [6]float32{})
+		if _, err := LoadDenseGrid(&file); err == nil {
+			t.Errorf("expected an error reading a %dx%dx%d dense grid without densities", header.Nx, header.Ny, header.Nz)
+		}
+	}
+	for _, blockCount := range []uint32{math.MaxUint32, 2} {
+		var file bytes.Buffer
+		file.WriteString(sparseGridMagic)
+		binary.Write(&file, binary.LittleEndian, [4]float32{0, 0, 0, 1})
+		binary.Write(&file, binary.LittleEndian, blockCount)
+		if _, err := LoadSparseGrid(&file); err == nil {
+			t.Errorf("expected an error reading a sparse grid of %d blocks without blocks", blockCount)
+		}
+	}
+
+	// a NaN or infinite voxel would keep delta tracking from ever ending, so grids with one are turned away
+	for _, bad := range []float32{float32(math.NaN()), float32(math.Inf(1)), -1} {
+		var file bytes.Buffer
+		file.WriteString(denseGridMagic)
+		binary.Write(&file, binary.LittleEndian, struct{ Nx, Ny, Nz uint32 }{2, 1, 1})
+		binary.Write(&file, binary.LittleEndian, [6]float32{0, 0, 0, 2, 1, 1})
+		binary.Write(&file, binary.LittleEndian, []float32{0, bad})
+		if _, err := LoadDenseGrid(&file); err == nil {
+			t.Errorf("expected an error reading a dense grid with a density of %f", bad)
+		}
+		file.Reset()
+		file.WriteString(sparseGridMagic)
+		binary.Write(&file, binary.LittleEndian, [4]float32{0, 0, 0, 1})
+		binary.Write(&file, binary.LittleEndian, uint32(1))
+		binary.Write(&file, binary.LittleEndian, [3]int32{})
+		block := sparseGridBlock{}
+		block[3] = bad
+		binary.Write(&file, binary.LittleEndian, block)
+		if _, err := LoadSparseGrid(&file); err == nil {
+			t.Errorf("expected an error reading a sparse grid with a density of %f", bad)
+		}
+	}
+	var file bytes.Buffer
+	file.WriteString(sparseGridMagic)
+	binary.Write(&file, binary.LittleEndian, [4]float32{0, 0, 0, 0})
+	binary.Write(&file, binary.LittleEndian, uint32(0))
+	if _, err := LoadSparseGrid(&file); err == nil {
+		t.Errorf("expected an error reading a sparse grid with a voxel size of 0")
+	}
+	// media whose grid got a NaN maximum anyway let rays through instead of tracking forever
+	nanGrid := NewDenseGrid(r3.Vec{}, r3.Vec{X: 1, Y: 1, Z: 1}, 1, 1, 1, []float32{1})
+	nanGrid.max = math.NaN()
+	nanMedium := HeterogeneousMedium{Grid: nanGrid, Scattering: 1}
+	nanRay := ray{p: r3.Vec{X: -1, Y: 0.5, Z: 0.5}, normalizedDirection: r3.Vec{X: 1}}
+	if hr := nanMedium.hit(&nanRay, 0, math.MaxFloat64); hr.t >= 0 {
+		t.Errorf("expected a ray through a grid with a NaN maximum to pass, got t=%f", hr.t)
+	}
+
+	for _, grid := range []DensityGrid{loadedDense, loadedSparse} {
+		if grid.maxDensity() != 1 {
+			t.Errorf("%T: expected max density 1, got %f", grid, grid.maxDensity())
+		}
+		for _, test := range []struct{ x, density float64 }{{0.5, 0}, {1, 0.5}, {1.5, 1}} {
+			if d := grid.density(r3.Vec{X: test.x, Y: 0.5, Z: 0.5}); math.Abs(d-test.density) > 1e-6 {
+				t.Errorf("%T: expected density %f at x=%f, got %f", grid, test.density, test.x, d)
+			}
+		}
+	}
+
+	// delta tracking through a uniform grid should let through the same fraction of rays as exp(-extinction * distance)
+	uniform := NewDenseGrid(r3.Vec{}, r3.Vec{X: 1, Y: 1, Z: 1}, 2, 2, 2, []float32{1, 1, 1, 1, 1, 1, 1, 1})
+	medium := HeterogeneousMedium{Grid: uniform, Absorption: 0.5, Scattering: 0.5, ColorFrac: r3.Vec{X: 1, Y: 1, Z: 1}}
+	r := ray{p: r3.Vec{X: -1, Y: 0.5, Z: 0.5}, normalizedDirection: r3.Vec{X: 1}}
+	rays := 20000
+	passed := 0
+	for i := 0; i < rays; i++ {
+		if medium.hit(&r, 0, math.MaxFloat64).t < 0 {
+			passed++
+		}
+	}
+	if fraction := float64(passed) / float64(rays); math.Abs(fraction-math.Exp(-1)) > 0.02 {
+		t.Errorf("expected %f of the rays to pass through the medium, got %f", math.Exp(-1), fraction)
+	}
+}
+
+func TestHeterogeneousMediumTransforms(t *testing.T) {
+	// a rod along the x axis, dense enough that any ray through it scatters
+	rod := HeterogeneousMedium{Grid: NewDenseGrid(r3.Vec{X: -2, Y: -0.1, Z: -0.1}, r3.Vec{X: 2, Y: 0.1, Z: 0.1}, 1, 1, 1, []float32{1}), Scattering: 1000}
+	hits := func(p r3.Vec, d r3.Vec) bool {
+		r := ray{p: p, normalizedDirection: d}
+		return rod.hit(&r, 0, math.MaxFloat64).t > 0
+	}
+	alongZ := func(x float64) bool { return hits(r3.Vec{X: x, Z: -10}, r3.Vec{Z: 1}) }
+	alongX := func(z float64) bool { return hits(r3.Vec{X: -10, Z: z}, r3.Vec{X: 1}) }
+	if !alongZ(1.5) || alongX(1.5) {
+		t.Errorf("expected the rod to lie along the x axis")
+	}
+
+	// turned to lie along the z axis
+	rod.Rotate(r3.Vec{Y: 90})
+	if alongZ(1.5) || !alongZ(0) || !alongX(1.5) {
+		t.Errorf("expected the rotated rod to lie along the z axis")
+	}
+	if lowest, highest := rod.computeSquareBounds(); math.Abs(lowest.Z+2) > 1e-9 || math.Abs(highest.Z-2) > 1e-9 || highest.X > 0.1+1e-9 {
+		t.Errorf("expected the bounds of the rotated rod to be along the z axis, got %v %v", lowest, highest)
+	}
+
+	// and half as long
+	rod.Scale(0.5)
+	if alongX(1.5) || !alongX(0.5) {
+		t.Errorf("expected the scaled rod to be half as long")
+	}
+}